@@ -0,0 +1,164 @@
+package gcsurl
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+)
+
+// ErrChecksumMismatch is returned by VerifyChecksum when a stored object's hashes differ from the expected ones
+var ErrChecksumMismatch = errors.New("stored object does not match the expected checksum")
+
+// crc32cTable is the Castagnoli table GCS uses for CRC32C
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Checksum holds base64-encoded hashes of an object's content, as in the x-goog-hash header
+type Checksum struct {
+	MD5    string `json:"md5,omitempty"`    // Base64 of the 16-byte MD5 digest
+	CRC32C string `json:"crc32c,omitempty"` // Base64 of the big-endian 4-byte CRC32C
+}
+
+// ChecksumReader is implemented by object stores that can return the hashes GCS stored for an object
+// The default object store implements it; VerifyChecksum requires it.
+type ChecksumReader interface {
+	ObjectChecksum(ctx context.Context, bucketName, objectName string) (Checksum, error)
+}
+
+// ComputeChecksum reads r to the end and returns its MD5 and CRC32C
+func ComputeChecksum(r io.Reader) (Checksum, error) {
+	md5Hash := md5.New()
+	crcHash := crc32.New(crc32cTable)
+	if _, err := io.Copy(io.MultiWriter(md5Hash, crcHash), r); err != nil {
+		return Checksum{}, fmt.Errorf("failed to compute checksum: %w", err)
+	}
+	return Checksum{
+		MD5:    base64.StdEncoding.EncodeToString(md5Hash.Sum(nil)),
+		CRC32C: base64.StdEncoding.EncodeToString(crcHash.Sum(nil)),
+	}, nil
+}
+
+// isZero reports whether no hash is set
+func (c Checksum) isZero() bool {
+	return c.MD5 == "" && c.CRC32C == ""
+}
+
+// validate checks that each set hash is base64 of the right length
+func (c Checksum) validate() error {
+	if c.MD5 != "" {
+		if digest, err := base64.StdEncoding.DecodeString(c.MD5); err != nil || len(digest) != md5.Size {
+			return inputErrorf("MD5 checksum must be the base64 of a %d-byte digest, got %q", md5.Size, c.MD5)
+		}
+	}
+	if c.CRC32C != "" {
+		if digest, err := base64.StdEncoding.DecodeString(c.CRC32C); err != nil || len(digest) != crc32.Size {
+			return inputErrorf("CRC32C checksum must be the base64 of a %d-byte value, got %q", crc32.Size, c.CRC32C)
+		}
+	}
+	return nil
+}
+
+// uploadHeaders returns the headers that make GCS reject a body with different hashes
+func (c Checksum) uploadHeaders() map[string]string {
+	headers := make(map[string]string)
+	var hashes []string
+	if c.CRC32C != "" {
+		hashes = append(hashes, "crc32c="+c.CRC32C)
+	}
+	if c.MD5 != "" {
+		headers["Content-MD5"] = c.MD5
+		hashes = append(hashes, "md5="+c.MD5)
+	}
+	if len(hashes) > 0 {
+		headers["x-goog-hash"] = strings.Join(hashes, ",")
+	}
+	return headers
+}
+
+// mismatch returns why actual does not satisfy the expected hashes, or "" when it does
+func (c Checksum) mismatch(actual Checksum) string {
+	var reasons []string
+	if c.MD5 != "" {
+		switch actual.MD5 {
+		case c.MD5:
+		case "":
+			// Composite objects, e.g. from multipart uploads, only have a CRC32C
+			reasons = append(reasons, "object has no MD5 hash")
+		default:
+			reasons = append(reasons, fmt.Sprintf("MD5 is %s, expected %s", actual.MD5, c.MD5))
+		}
+	}
+	if c.CRC32C != "" && actual.CRC32C != c.CRC32C {
+		reasons = append(reasons, fmt.Sprintf("CRC32C is %s, expected %s", actual.CRC32C, c.CRC32C))
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// GenerateSignedUploadURLWithChecksum generates a signed upload URL for the default bucket bound to a checksum
+// GCS rejects the upload with 400 Bad Request when the body does not match, so corrupted or
+// substituted files never replace the object. Set at least one of the MD5 and CRC32C.
+func (u *URLGenerator) GenerateSignedUploadURLWithChecksum(ctx context.Context, objectName string, checksum Checksum) (DocumentUpload, error) {
+	if checksum.isZero() {
+		return DocumentUpload{}, inputErrorf("checksum must set MD5 or CRC32C")
+	}
+	return u.GenerateSignedUploadURLWithOptions(ctx, u.bucketName, objectName, UploadOptions{Checksum: checksum})
+}
+
+// VerifyChecksum checks the hashes GCS stored for an uploaded object against the expected ones
+// It returns the stored hashes, and an error wrapping ErrChecksumMismatch when they differ.
+func (u *URLGenerator) VerifyChecksum(ctx context.Context, bucketName, objectName string, expected Checksum) (Checksum, error) {
+	if expected.isZero() {
+		return Checksum{}, inputErrorf("checksum must set MD5 or CRC32C")
+	}
+	if err := expected.validate(); err != nil {
+		return Checksum{}, err
+	}
+	if err := u.checkObjectKey(objectName); err != nil {
+		return Checksum{}, err
+	}
+
+	reader, ok := u.getObjectStore().(ChecksumReader)
+	if !ok {
+		return Checksum{}, fmt.Errorf("object store does not implement ChecksumReader")
+	}
+	actual, err := reader.ObjectChecksum(ctx, bucketName, objectName)
+	if err != nil {
+		return Checksum{}, fmt.Errorf("failed to read checksum of %s/%s: %w", bucketName, objectName, err)
+	}
+	if reason := expected.mismatch(actual); reason != "" {
+		return actual, fmt.Errorf("%w: %s/%s: %s", ErrChecksumMismatch, bucketName, objectName, reason)
+	}
+	return actual, nil
+}
+
+// ObjectChecksum returns the MD5 and CRC32C GCS stored for the object
+func (s *storageObjectStore) ObjectChecksum(ctx context.Context, bucketName, objectName string) (Checksum, error) {
+	client, err := s.generator.CreateStorageClient(ctx)
+	if err != nil {
+		return Checksum{}, err
+	}
+	defer client.Close()
+
+	// CSEK objects only report their hashes when the key is supplied
+	object, err := s.object(ctx, client, bucketName, objectName)
+	if err != nil {
+		return Checksum{}, err
+	}
+	attrs, err := object.Attrs(ctx)
+	if err != nil {
+		return Checksum{}, err
+	}
+
+	var checksum Checksum
+	if len(attrs.MD5) > 0 {
+		checksum.MD5 = base64.StdEncoding.EncodeToString(attrs.MD5)
+	}
+	crc := binary.BigEndian.AppendUint32(nil, attrs.CRC32C)
+	checksum.CRC32C = base64.StdEncoding.EncodeToString(crc)
+	return checksum, nil
+}
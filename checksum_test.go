@@ -0,0 +1,127 @@
+package gcsurl
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// checksumObjectStore is a memoryObjectStore that also reports the hashes of its objects
+// Objects listed in composite only have a CRC32C, like objects from multipart uploads.
+type checksumObjectStore struct {
+	memoryObjectStore
+	composite map[string]bool
+}
+
+func (s checksumObjectStore) ObjectChecksum(ctx context.Context, bucketName, objectName string) (Checksum, error) {
+	data, ok := s.memoryObjectStore[bucketName+"/"+objectName]
+	if !ok {
+		return Checksum{}, errors.New("object not found")
+	}
+	checksum, err := ComputeChecksum(strings.NewReader(string(data)))
+	if s.composite[bucketName+"/"+objectName] {
+		checksum.MD5 = ""
+	}
+	return checksum, err
+}
+
+func TestChecksumValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		checksum Checksum
+		wantErr  bool
+	}{
+		{"empty", Checksum{}, false},
+		{"MD5", Checksum{MD5: "1B2M2Y8AsgTpgAmY7PhCfg=="}, false},
+		{"CRC32C", Checksum{CRC32C: "AAAAAA=="}, false},
+		{"both", Checksum{MD5: "1B2M2Y8AsgTpgAmY7PhCfg==", CRC32C: "AAAAAA=="}, false},
+		{"malformed MD5", Checksum{MD5: "not base64!"}, true},
+		{"hex MD5", Checksum{MD5: "d41d8cd98f00b204e9800998ecf8427e"}, true},
+		{"short MD5", Checksum{MD5: "AAAAAA=="}, true},
+		{"unpadded MD5", Checksum{MD5: "1B2M2Y8AsgTpgAmY7PhCfg"}, true},
+		{"malformed CRC32C", Checksum{CRC32C: "%%%%"}, true},
+		{"long CRC32C", Checksum{CRC32C: "1B2M2Y8AsgTpgAmY7PhCfg=="}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.checksum.validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidInput) {
+				t.Errorf("validate() error = %v, want ErrInvalidInput", err)
+			}
+		})
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	store := checksumObjectStore{
+		memoryObjectStore: memoryObjectStore{
+			"b/report.pdf": []byte("%PDF-1.7 report"),
+			"b/video.mp4":  []byte("composite video"),
+		},
+		composite: map[string]bool{"b/video.mp4": true},
+	}
+	generator := &URLGenerator{objectStore: store}
+	stored, err := ComputeChecksum(strings.NewReader("%PDF-1.7 report"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ComputeChecksum(strings.NewReader("something else"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		object     string
+		expected   Checksum
+		wantReason string // Part of the ErrChecksumMismatch message; "" means the checksum matches
+	}{
+		{"both match", "report.pdf", stored, ""},
+		{"MD5 matches", "report.pdf", Checksum{MD5: stored.MD5}, ""},
+		{"CRC32C matches", "report.pdf", Checksum{CRC32C: stored.CRC32C}, ""},
+		{"MD5 differs", "report.pdf", Checksum{MD5: other.MD5}, "MD5 is " + stored.MD5 + ", expected " + other.MD5},
+		{"CRC32C differs", "report.pdf", Checksum{CRC32C: other.CRC32C}, "CRC32C is " + stored.CRC32C + ", expected " + other.CRC32C},
+		{"both differ", "report.pdf", other, "MD5 is " + stored.MD5 + ", expected " + other.MD5 + "; CRC32C is"},
+		{"composite object without MD5", "video.mp4", Checksum{MD5: other.MD5}, "object has no MD5 hash"},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := generator.VerifyChecksum(ctx, "b", tt.object, tt.expected)
+			if tt.wantReason == "" {
+				if err != nil {
+					t.Fatalf("VerifyChecksum() error = %v", err)
+				}
+				if actual != stored {
+					t.Errorf("VerifyChecksum() = %+v, want %+v", actual, stored)
+				}
+				return
+			}
+			if !errors.Is(err, ErrChecksumMismatch) || !strings.Contains(err.Error(), "b/"+tt.object+": "+tt.wantReason) {
+				t.Fatalf("VerifyChecksum() error = %v, want ErrChecksumMismatch with %q", err, tt.wantReason)
+			}
+			if actual.CRC32C == "" {
+				t.Errorf("VerifyChecksum() = %+v, want the stored hashes with the mismatch", actual)
+			}
+		})
+	}
+
+	for name, expected := range map[string]Checksum{"no hash": {}, "malformed hash": {MD5: "abc"}} {
+		if _, err := generator.VerifyChecksum(ctx, "b", "report.pdf", expected); !errors.Is(err, ErrInvalidInput) || errors.Is(err, ErrChecksumMismatch) {
+			t.Errorf("%s: error = %v, want ErrInvalidInput", name, err)
+		}
+	}
+	if _, err := generator.VerifyChecksum(ctx, "b", "missing.pdf", stored); err == nil || errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("missing object: error = %v, want a read error", err)
+	}
+	plain := &URLGenerator{objectStore: memoryObjectStore{}}
+	if _, err := plain.VerifyChecksum(ctx, "b", "report.pdf", stored); err == nil || errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("store without ChecksumReader: error = %v, want an error", err)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/tropikoearth/gcsurl"
+)
+
+// curl runs the curl command
+func curl(ctx context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("curl", flag.ContinueOnError)
+	var upload uploadFlags
+	upload.register(fs)
+	var download downloadFlags
+	fs.StringVar(&download.disposition, "disposition", "", `download only: "attachment" or "inline"`)
+	fs.StringVar(&download.filename, "filename", "", "download only: name the browser saves the file as")
+	isDownload := fs.Bool("download", false, "print a download command instead of an upload")
+	checksum := fs.Bool("checksum", false, "upload only: bind the URL to the MD5 and CRC32C of file")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gcsurl curl [flags] <object> <file>\n       gcsurl curl -download [flags] <object> [output]\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 || fs.NArg() > 2 || !*isDownload && fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("expected <object> and a file")
+	}
+	objectName, file := fs.Arg(0), fs.Arg(1)
+
+	if *isDownload {
+		download.signingFlags = upload.signingFlags
+		doc, err := download.sign(ctx, objectName)
+		if err != nil {
+			return err
+		}
+		if file == "" {
+			file = path.Base(objectName)
+		}
+		command := []string{"curl", "--fail", "-o", shellQuote(file)}
+		command = append(command, headerArgs(doc.Headers)...)
+		command = append(command, shellQuote(doc.DownloadURL))
+		_, err = fmt.Fprintln(out, strings.Join(command, " "))
+		return err
+	}
+
+	if *checksum {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if upload.checksum, err = gcsurl.ComputeChecksum(f); err != nil {
+			return err
+		}
+	}
+	doc, err := upload.sign(ctx, objectName)
+	if err != nil {
+		return err
+	}
+	command := []string{"curl", "--fail", "-X", "PUT"}
+	command = append(command, headerArgs(doc.Headers)...)
+	command = append(command, "--upload-file", shellQuote(file), shellQuote(doc.UploadURL))
+	_, err = fmt.Fprintln(out, strings.Join(command, " "))
+	return err
+}
+
+// headerArgs returns sorted curl -H arguments for the headers
+func headerArgs(headers map[string]string) []string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	args := make([]string, 0, 2*len(names))
+	for _, name := range names {
+		args = append(args, "-H", shellQuote(name+": "+headers[name]))
+	}
+	return args
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
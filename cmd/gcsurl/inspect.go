@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tropikoearth/gcsurl"
+)
+
+// urlInfo is what inspect decodes from a signed URL
+type urlInfo struct {
+	Scheme         string            `json:"scheme"` // "V4" or "V2"
+	Bucket         string            `json:"bucket"`
+	Object         string            `json:"object"`
+	Algorithm      string            `json:"algorithm,omitempty"`
+	AccessID       string            `json:"accessId"`
+	Scope          string            `json:"scope,omitempty"` // date/location/service/request
+	SignedAt       time.Time         `json:"signedAt,omitzero"`
+	ExpiresAt      time.Time         `json:"expiresAt"`
+	Expired        bool              `json:"expired"`
+	SignedHeaders  []string          `json:"signedHeaders,omitempty"`
+	QueryParams    map[string]string `json:"queryParams,omitempty"` // Signed query parameters besides the signature ones
+	SignatureBytes int               `json:"signatureBytes"`
+	Verification   string            `json:"verification,omitempty"` // Outcome of -key verification
+}
+
+// inspect runs the inspect command
+func inspect(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+	keyFile := fs.String("key", "", "verify the V4 signature with this PEM certificate, public key or service account JSON")
+	method := fs.String("method", "", "method the client uses, for -key (default: GET)")
+	var headers headerFlag
+	fs.Var(&headers, "H", `header the client sends as "Name: value", for -key (repeatable)`)
+	rawURL, err := parseArgs(fs, args, "<url>")
+	if err != nil {
+		return err
+	}
+
+	info, err := inspectURL(rawURL, time.Now())
+	if err != nil {
+		return err
+	}
+	if *keyFile != "" {
+		info.Verification, err = verifyURL(rawURL, *keyFile, *method, http.Header(headers))
+		if err != nil {
+			return err
+		}
+	}
+	if *asJSON {
+		return writeJSON(out, info)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Scheme:\t%s\n", info.Scheme)
+	fmt.Fprintf(w, "Bucket:\t%s\n", info.Bucket)
+	fmt.Fprintf(w, "Object:\t%s\n", info.Object)
+	if info.Algorithm != "" {
+		fmt.Fprintf(w, "Algorithm:\t%s\n", info.Algorithm)
+	}
+	fmt.Fprintf(w, "Access ID:\t%s\n", info.AccessID)
+	if info.Scope != "" {
+		fmt.Fprintf(w, "Credential scope:\t%s\n", info.Scope)
+	}
+	if !info.SignedAt.IsZero() {
+		fmt.Fprintf(w, "Signed at:\t%s\n", info.SignedAt.Format(time.RFC3339))
+	}
+	remaining := time.Until(info.ExpiresAt).Round(time.Second)
+	if info.Expired {
+		fmt.Fprintf(w, "Expires at:\t%s (expired %s ago)\n", info.ExpiresAt.Format(time.RFC3339), -remaining)
+	} else {
+		fmt.Fprintf(w, "Expires at:\t%s (in %s)\n", info.ExpiresAt.Format(time.RFC3339), remaining)
+	}
+	if len(info.SignedHeaders) > 0 {
+		fmt.Fprintf(w, "Signed headers:\t%s\n", strings.Join(info.SignedHeaders, ", "))
+	}
+	names := make([]string, 0, len(info.QueryParams))
+	for name := range info.QueryParams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "Query %s:\t%s\n", name, info.QueryParams[name])
+	}
+	fmt.Fprintf(w, "Signature:\t%d bytes\n", info.SignatureBytes)
+	if info.Verification != "" {
+		fmt.Fprintf(w, "Verification:\t%s\n", info.Verification)
+	}
+	return w.Flush()
+}
+
+// verifyURL checks the V4 signature of rawURL and describes the outcome
+func verifyURL(rawURL, keyFile, method string, headers http.Header) (string, error) {
+	if method == "" {
+		method = http.MethodGet
+	}
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return "", err
+	}
+	// Service account key files hold the private key in JSON
+	var serviceAccount gcsurl.ServiceAccount
+	if json.Unmarshal(data, &serviceAccount) == nil && serviceAccount.PrivateKey != "" {
+		data = []byte(serviceAccount.PrivateKey)
+	}
+	publicKey, err := gcsurl.ParsePublicKeyPEM(data)
+	if err != nil {
+		return "", err
+	}
+
+	signed, err := gcsurl.ParseSignedURL(rawURL)
+	if err != nil {
+		return "", err
+	}
+	err = signed.Verify(gcsurl.SignatureVerifyOptions{Method: method, Headers: headers, PublicKey: publicKey})
+	var signatureErr *gcsurl.SignatureError
+	switch {
+	case err == nil:
+		return "signature is valid for " + method, nil
+	case errors.As(err, &signatureErr):
+		return fmt.Sprintf("FAILED (%s): %s", signatureErr.Component, signatureErr.Reason), nil
+	}
+	return "", err
+}
+
+// headerFlag collects repeated "Name: value" flags
+type headerFlag http.Header
+
+func (f *headerFlag) String() string {
+	return fmt.Sprint(http.Header(*f))
+}
+
+func (f *headerFlag) Set(header string) error {
+	name, value, ok := strings.Cut(header, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf(`expected "Name: value", got %q`, header)
+	}
+	if *f == nil {
+		*f = make(headerFlag)
+	}
+	http.Header(*f).Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	return nil
+}
+
+// inspectURL decodes a V4 or V2 signed URL
+func inspectURL(rawURL string, now time.Time) (urlInfo, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return urlInfo{}, fmt.Errorf("invalid URL: %w", err)
+	}
+	query := u.Query()
+
+	info := urlInfo{QueryParams: make(map[string]string)}
+
+	switch {
+	case query.Has("X-Goog-Signature"):
+		signed, err := gcsurl.ParseSignedURL(rawURL)
+		if err != nil {
+			return urlInfo{}, err
+		}
+		info.Scheme = "V4"
+		info.Bucket, info.Object = signed.Bucket, signed.Object
+		info.Algorithm = signed.Algorithm
+		info.AccessID, info.Scope = signed.AccessID, signed.CredentialScope
+		info.SignedAt, info.ExpiresAt = signed.Date, signed.ExpiresAt()
+		info.SignedHeaders = signed.SignedHeaders
+		info.SignatureBytes = len(signed.Signature)
+	case query.Has("Signature"):
+		info.Scheme = "V2"
+		info.Bucket, info.Object = bucketAndObject(u)
+		info.AccessID = query.Get("GoogleAccessId")
+		seconds, err := strconv.ParseInt(query.Get("Expires"), 10, 64)
+		if err != nil {
+			return urlInfo{}, fmt.Errorf("invalid Expires %q", query.Get("Expires"))
+		}
+		info.ExpiresAt = time.Unix(seconds, 0).UTC()
+		info.SignatureBytes = len(query.Get("Signature")) * 3 / 4 // Base64 encoded
+	default:
+		return urlInfo{}, fmt.Errorf("URL has no X-Goog-Signature or Signature parameter")
+	}
+	info.Expired = !now.Before(info.ExpiresAt)
+
+	for name, values := range query {
+		if strings.HasPrefix(name, "X-Goog-") || name == "GoogleAccessId" || name == "Expires" || name == "Signature" {
+			continue
+		}
+		info.QueryParams[name] = strings.Join(values, ",")
+	}
+	return info, nil
+}
+
+// bucketAndObject splits a path-style or virtual-hosted-style URL into bucket and object
+func bucketAndObject(u *url.URL) (string, string) {
+	path := strings.TrimPrefix(u.Path, "/")
+	if bucket, ok := strings.CutSuffix(u.Hostname(), ".storage.googleapis.com"); ok {
+		return bucket, path
+	}
+	bucket, object, _ := strings.Cut(path, "/")
+	return bucket, object
+}
@@ -0,0 +1,262 @@
+// Command gcsurl signs, inspects and debugs Google Cloud Storage signed URLs
+//
+// Usage:
+//
+//	gcsurl sign-upload [flags] <object>
+//	gcsurl sign-download [flags] <object>
+//	gcsurl inspect [flags] <url>
+//	gcsurl curl [flags] <object> [file]
+//
+// It is configured with the same environment variables as gcsurl.NewURLGenerator, plus the
+// upload restriction variables read by gcsurl.NewUploadRestrictionsFromEnv.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tropikoearth/gcsurl"
+)
+
+const usage = `gcsurl signs, inspects and debugs Google Cloud Storage signed URLs.
+
+Usage:
+  gcsurl sign-upload [flags] <object>     Sign an upload URL and print it as JSON
+  gcsurl sign-download [flags] <object>   Sign a download URL and print it as JSON
+  gcsurl inspect [flags] <url>            Decode a URL and, with -key, verify its signature
+  gcsurl curl [flags] <object> [file]     Print the curl command that uploads file (or downloads with -download)
+
+Run "gcsurl <command> -h" for the flags of a command.
+
+Environment:
+  GCS_BUCKET_NAME                  Default bucket (or -bucket)
+  GCS_SERVICE_ACCOUNT_JSON         Service account JSON as string
+  GOOGLE_APPLICATION_CREDENTIALS   Path to service account JSON file
+  GCP_PROJECT_ID                   GCP project ID
+  GCS_DEFAULT_EXPIRY_MINUTES       Default URL expiry in minutes (default: 15)
+  GCS_SIGNING_SERVICE_ACCOUNT      Service account for IAM signBlob signing
+  GCS_IAM_CREDENTIALS_ENDPOINT     IAM Credentials API base URL
+  GCS_ROOT_PREFIX                  Prefix every upload key is kept under
+  GCS_ENDPOINT                     Storage endpoint instead of https://storage.googleapis.com
+  GCS_ALLOW_MULTIPLE_UPLOADS, GCS_ALLOWED_FILE_EXTENSIONS, GCS_MAX_FILE_SIZE_MB,
+  GCS_MIN_FILE_SIZE_BYTES, GCS_ALLOWED_MIME_TYPES
+                                   Upload restrictions
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var err error
+	command, args := os.Args[1], os.Args[2:]
+	switch command {
+	case "sign-upload":
+		err = signUpload(ctx, args, os.Stdout)
+	case "sign-download":
+		err = signDownload(ctx, args, os.Stdout)
+	case "inspect":
+		err = inspect(args, os.Stdout)
+	case "curl":
+		err = curl(ctx, args, os.Stdout)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(os.Stdout, usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "gcsurl: unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gcsurl %s: %v\n", command, err)
+		os.Exit(1)
+	}
+}
+
+// signingFlags are the flags shared by the signing commands
+type signingFlags struct {
+	bucket string
+	expiry time.Duration
+}
+
+// register adds the shared flags to fs
+func (f *signingFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.bucket, "bucket", "", "bucket name (default: $GCS_BUCKET_NAME)")
+	fs.DurationVar(&f.expiry, "expiry", 0, "URL expiry, e.g. 1h (default: $GCS_DEFAULT_EXPIRY_MINUTES or 15m)")
+}
+
+// newGenerator creates a URL generator from the flags and the environment
+func (f *signingFlags) newGenerator() (*gcsurl.URLGenerator, error) {
+	return gcsurl.NewURLGeneratorWithConfig(gcsurl.Config{
+		BucketName:            f.bucket,
+		ProjectID:             os.Getenv("GCP_PROJECT_ID"),
+		ServiceAccountKeyPath: os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"),
+		UploadRestrictions:    gcsurl.NewUploadRestrictionsFromEnv(),
+	})
+}
+
+// uploadFlags are the flags of the upload commands
+type uploadFlags struct {
+	signingFlags
+	original bool
+	metadata keyValueFlag
+	checksum gcsurl.Checksum
+}
+
+// register adds the upload flags to fs
+func (f *uploadFlags) register(fs *flag.FlagSet) {
+	f.signingFlags.register(fs)
+	fs.BoolVar(&f.original, "original", false, "sign the exact object name instead of generating a unique one")
+	fs.Var(&f.metadata, "meta", "custom metadata as key=value (repeatable)")
+	fs.StringVar(&f.checksum.MD5, "md5", "", "expected base64 MD5 of the file (Content-MD5)")
+	fs.StringVar(&f.checksum.CRC32C, "crc32c", "", "expected base64 CRC32C of the file (x-goog-hash)")
+}
+
+// sign signs an upload URL for objectName
+func (f *uploadFlags) sign(ctx context.Context, objectName string) (gcsurl.DocumentUpload, error) {
+	generator, err := f.newGenerator()
+	if err != nil {
+		return gcsurl.DocumentUpload{}, err
+	}
+	return generator.GenerateSignedUploadURLWithOptions(ctx, generator.GetBucketName(), objectName, gcsurl.UploadOptions{
+		Expiry:          f.expiry,
+		UseOriginalName: f.original,
+		Metadata:        f.metadata,
+		Checksum:        f.checksum,
+	})
+}
+
+// downloadFlags are the flags of the download commands
+type downloadFlags struct {
+	signingFlags
+	disposition string
+	filename    string
+}
+
+// register adds the download flags to fs
+func (f *downloadFlags) register(fs *flag.FlagSet) {
+	f.signingFlags.register(fs)
+	fs.StringVar(&f.disposition, "disposition", "", `"attachment" or "inline"`)
+	fs.StringVar(&f.filename, "filename", "", "name the browser saves the file as")
+}
+
+// sign signs a download URL for objectName
+func (f *downloadFlags) sign(ctx context.Context, objectName string) (gcsurl.DocumentDownload, error) {
+	generator, err := f.newGenerator()
+	if err != nil {
+		return gcsurl.DocumentDownload{}, err
+	}
+	return generator.GenerateSignedDownloadWithOptions(ctx, generator.GetBucketName(), objectName, gcsurl.DownloadOptions{
+		Expiry:      f.expiry,
+		Disposition: f.disposition,
+		Filename:    f.filename,
+	})
+}
+
+// signUpload runs the sign-upload command
+func signUpload(ctx context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("sign-upload", flag.ContinueOnError)
+	var flags uploadFlags
+	flags.register(fs)
+	urlOnly := fs.Bool("url", false, "print only the URL")
+	objectName, err := parseArgs(fs, args, "<object>")
+	if err != nil {
+		return err
+	}
+
+	upload, err := flags.sign(ctx, objectName)
+	if err != nil {
+		return err
+	}
+	if *urlOnly {
+		_, err := fmt.Fprintln(out, upload.UploadURL)
+		return err
+	}
+	return writeJSON(out, upload)
+}
+
+// signDownload runs the sign-download command
+func signDownload(ctx context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("sign-download", flag.ContinueOnError)
+	var flags downloadFlags
+	flags.register(fs)
+	urlOnly := fs.Bool("url", false, "print only the URL")
+	objectName, err := parseArgs(fs, args, "<object>")
+	if err != nil {
+		return err
+	}
+
+	download, err := flags.sign(ctx, objectName)
+	if err != nil {
+		return err
+	}
+	if *urlOnly {
+		_, err := fmt.Fprintln(out, download.DownloadURL)
+		return err
+	}
+	return writeJSON(out, download)
+}
+
+// parseArgs parses the flags and returns the single positional argument
+func parseArgs(fs *flag.FlagSet, args []string, argName string) (string, error) {
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gcsurl %s [flags] %s\n\nFlags:\n", fs.Name(), argName)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return "", err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return "", fmt.Errorf("expected %s, got %d arguments", argName, fs.NArg())
+	}
+	return fs.Arg(0), nil
+}
+
+// writeJSON prints v as indented JSON
+func writeJSON(out io.Writer, v any) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(v)
+}
+
+// keyValueFlag collects repeated key=value flags
+type keyValueFlag map[string]string
+
+func (f *keyValueFlag) String() string {
+	pairs := make([]string, 0, len(*f))
+	for key, value := range *f {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (f *keyValueFlag) Set(pair string) error {
+	key, value, ok := strings.Cut(pair, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("expected key=value, got %q", pair)
+	}
+	if *f == nil {
+		*f = make(keyValueFlag)
+	}
+	(*f)[key] = value
+	return nil
+}
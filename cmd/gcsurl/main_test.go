@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tropikoearth/gcsurl"
+)
+
+// setupEnv clears the configuration environment and signs with a fresh service account key file
+// It returns the path of the key file, which inspect -key also accepts.
+func setupEnv(t *testing.T) string {
+	t.Helper()
+	for _, name := range []string{
+		"GCS_BUCKET_NAME", "GCS_SERVICE_ACCOUNT_JSON", "GCP_PROJECT_ID", "GCS_DEFAULT_EXPIRY_MINUTES",
+		"GCS_SIGNING_SERVICE_ACCOUNT", "GCS_IAM_CREDENTIALS_ENDPOINT", "GCS_ROOT_PREFIX", "GCS_ENDPOINT",
+		"GCS_ALLOW_MULTIPLE_UPLOADS", "GCS_ALLOWED_FILE_EXTENSIONS", "GCS_MAX_FILE_SIZE_MB",
+		"GCS_MIN_FILE_SIZE_BYTES", "GCS_ALLOWED_MIME_TYPES",
+	} {
+		t.Setenv(name, "")
+	}
+
+	signer, err := gcsurl.NewTestSigner("cli@gcsurl.iam.gserviceaccount.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey, err := signer.PrivateKeyPEM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(gcsurl.ServiceAccount{ClientEmail: "cli@gcsurl.iam.gserviceaccount.com", PrivateKey: string(privateKey)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "key.json")
+	if err := os.WriteFile(keyFile, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", keyFile)
+	return keyFile
+}
+
+func TestKeyValueFlag(t *testing.T) {
+	var f keyValueFlag
+	for _, pair := range []string{"owner=u123", "note=a=b", "empty="} {
+		if err := f.Set(pair); err != nil {
+			t.Fatalf("Set(%q) error = %v", pair, err)
+		}
+	}
+	if got, want := f.String(), "empty=,note=a=b,owner=u123"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	for _, pair := range []string{"owner", "=value"} {
+		if err := f.Set(pair); err == nil {
+			t.Errorf("Set(%q): want an error", pair)
+		}
+	}
+}
+
+func TestHeaderFlag(t *testing.T) {
+	var f headerFlag
+	for _, header := range []string{"Content-Type: application/pdf", "x-goog-meta-owner:u123", "X-Goog-Meta-Owner: u456"} {
+		if err := f.Set(header); err != nil {
+			t.Fatalf("Set(%q) error = %v", header, err)
+		}
+	}
+	if got := f["Content-Type"]; len(got) != 1 || got[0] != "application/pdf" {
+		t.Errorf("Content-Type = %v, want [application/pdf]", got)
+	}
+	if got := f["X-Goog-Meta-Owner"]; len(got) != 2 || got[0] != "u123" || got[1] != "u456" {
+		t.Errorf("X-Goog-Meta-Owner = %v, want [u123 u456]", got)
+	}
+	for _, header := range []string{"no colon", ": value"} {
+		if err := f.Set(header); err == nil {
+			t.Errorf("Set(%q): want an error", header)
+		}
+	}
+}
+
+func TestSignUploadFlagsAndEnv(t *testing.T) {
+	setupEnv(t)
+	t.Setenv("GCS_BUCKET_NAME", "env-bucket")
+	t.Setenv("GCS_DEFAULT_EXPIRY_MINUTES", "5")
+	t.Setenv("GCS_ALLOWED_FILE_EXTENSIONS", "pdf")
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantBucket string
+		wantExpiry time.Duration
+		wantKey    string // Exact key; "" means a generated key under reports/
+	}{
+		{"environment defaults", []string{"reports/q1.pdf"}, "env-bucket", 5 * time.Minute, ""},
+		{"flags override", []string{"-bucket", "flag-bucket", "-expiry", "2m", "reports/q1.pdf"}, "flag-bucket", 2 * time.Minute, ""},
+		{"original name", []string{"-original", "reports/q1.pdf"}, "env-bucket", 5 * time.Minute, "reports/q1.pdf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			args := append([]string{"-meta", "owner=u123", "-md5", "1B2M2Y8AsgTpgAmY7PhCfg=="}, tt.args...)
+			if err := signUpload(ctx, args, &out); err != nil {
+				t.Fatal(err)
+			}
+			var upload gcsurl.DocumentUpload
+			if err := json.Unmarshal(out.Bytes(), &upload); err != nil {
+				t.Fatalf("invalid JSON output %q: %v", out.String(), err)
+			}
+
+			signed, err := gcsurl.ParseSignedURL(upload.UploadURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// X-Goog-Date has whole seconds, so the signed expiry can be up to a second short
+			if signed.Bucket != tt.wantBucket || signed.Expires > tt.wantExpiry || signed.Expires < tt.wantExpiry-time.Second {
+				t.Errorf("signed for %s, %v, want %s, %v", signed.Bucket, signed.Expires, tt.wantBucket, tt.wantExpiry)
+			}
+			if tt.wantKey != "" && upload.GeneratedKey != tt.wantKey || tt.wantKey == "" && (upload.GeneratedKey == "reports/q1.pdf" || !strings.HasPrefix(upload.GeneratedKey, "reports/")) {
+				t.Errorf("GeneratedKey = %q, want %q", upload.GeneratedKey, tt.wantKey)
+			}
+			if upload.Headers["x-goog-meta-owner"] != "u123" || upload.Headers["Content-MD5"] != "1B2M2Y8AsgTpgAmY7PhCfg==" || upload.Headers["Content-Type"] != "application/pdf" {
+				t.Errorf("Headers = %v", upload.Headers)
+			}
+		})
+	}
+
+	var out bytes.Buffer
+	if err := signUpload(ctx, []string{"-url", "reports/q1.pdf"}, &out); err != nil || !strings.HasPrefix(out.String(), "https://") || strings.Count(out.String(), "\n") != 1 {
+		t.Errorf("-url output = %q, %v, want a single URL line", out.String(), err)
+	}
+	if err := signUpload(ctx, []string{"setup.exe"}, &out); !errors.Is(err, gcsurl.ErrInvalidInput) {
+		t.Errorf("disallowed extension: error = %v, want ErrInvalidInput", err)
+	}
+	if err := signUpload(ctx, []string{"-meta", "novalue", "a.pdf"}, &out); err == nil {
+		t.Error("malformed -meta: want an error")
+	}
+	if err := signUpload(ctx, []string{"a.pdf", "b.pdf"}, &out); err == nil {
+		t.Error("two objects: want an error")
+	}
+	if err := signUpload(ctx, []string{"-h"}, &out); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("-h: error = %v, want flag.ErrHelp", err)
+	}
+}
+
+func TestInspectRoundTrip(t *testing.T) {
+	keyFile := setupEnv(t)
+	t.Setenv("GCS_BUCKET_NAME", "documents")
+	ctx := context.Background()
+
+	var signed bytes.Buffer
+	if err := signDownload(ctx, []string{"-url", "-filename", "Q1 report.pdf", "reports/a1b2c3d4_q1.pdf"}, &signed); err != nil {
+		t.Fatal(err)
+	}
+	downloadURL := strings.TrimSpace(signed.String())
+
+	var out bytes.Buffer
+	if err := inspect([]string{"-json", "-key", keyFile, downloadURL}, &out); err != nil {
+		t.Fatal(err)
+	}
+	var info urlInfo
+	if err := json.Unmarshal(out.Bytes(), &info); err != nil {
+		t.Fatalf("invalid JSON output %q: %v", out.String(), err)
+	}
+	if info.Scheme != "V4" || info.Bucket != "documents" || info.Object != "reports/a1b2c3d4_q1.pdf" || info.AccessID != "cli@gcsurl.iam.gserviceaccount.com" || info.Expired {
+		t.Errorf("inspect = %+v", info)
+	}
+	if info.Verification != "signature is valid for GET" {
+		t.Errorf("Verification = %q, want a valid signature", info.Verification)
+	}
+	if disposition := info.QueryParams["response-content-disposition"]; disposition != `attachment; filename="Q1 report.pdf"` {
+		t.Errorf("response-content-disposition = %q", disposition)
+	}
+
+	// The same URL used with another method, or tampered with, fails verification
+	out.Reset()
+	if err := inspect([]string{"-key", keyFile, "-method", "PUT", downloadURL}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Verification:") || !strings.Contains(out.String(), "FAILED") {
+		t.Errorf("PUT verification output:\n%s\nwant FAILED", out.String())
+	}
+	tampered, err := url.Parse(downloadURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered.Path = strings.Replace(tampered.Path, "q1.pdf", "q2.pdf", 1)
+	out.Reset()
+	if err := inspect([]string{"-json", "-key", keyFile, tampered.String()}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(out.Bytes(), &info); err != nil || !strings.HasPrefix(info.Verification, "FAILED") {
+		t.Errorf("tampered URL: Verification = %q, %v, want FAILED", info.Verification, err)
+	}
+
+	// Upload URLs verify only with the headers they were signed with
+	signed.Reset()
+	if err := signUpload(ctx, []string{"-meta", "owner=u123", "reports/q1.pdf"}, &signed); err != nil {
+		t.Fatal(err)
+	}
+	var upload gcsurl.DocumentUpload
+	if err := json.Unmarshal(signed.Bytes(), &upload); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"-json", "-key", keyFile, "-method", "PUT"}
+	for name, value := range upload.Headers {
+		args = append(args, "-H", name+": "+value)
+	}
+	out.Reset()
+	if err := inspect(append(args, upload.UploadURL), &out); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(out.Bytes(), &info); err != nil || info.Verification != "signature is valid for PUT" {
+		t.Errorf("upload URL: Verification = %q, %v, want a valid signature", info.Verification, err)
+	}
+	out.Reset()
+	if err := inspect([]string{"-json", "-key", keyFile, "-method", "PUT", upload.UploadURL}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(out.Bytes(), &info); err != nil || !strings.HasPrefix(info.Verification, "FAILED") {
+		t.Errorf("upload URL without headers: Verification = %q, %v, want FAILED", info.Verification, err)
+	}
+
+	if err := inspect([]string{"https://storage.googleapis.com/documents/a.pdf"}, &out); err == nil {
+		t.Error("unsigned URL: want an error")
+	}
+}
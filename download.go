@@ -0,0 +1,189 @@
+package gcsurl
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// Content-Disposition types for downloads
+const (
+	DispositionAttachment = "attachment" // Browser saves the file
+	DispositionInline     = "inline"     // Browser displays the file when it can
+)
+
+// DownloadOptions customizes a signed download URL
+type DownloadOptions struct {
+	// Expiry overrides the default expiry when set
+	Expiry time.Duration
+	// Disposition is DispositionAttachment or DispositionInline; attachment is used when only Filename is set
+	Disposition string
+	// Filename is the name the user's browser saves the file as
+	// Defaults to the original name restored from the generated key when Disposition is set.
+	Filename string
+	// ContentType overrides the Content-Type GCS serves the object with
+	ContentType string
+	// EncryptionKey is the CSEK key the object was written with, instead of Config.EncryptionKeys
+	EncryptionKey *EncryptionKey
+}
+
+// DocumentDownload contains the signed download URL and expiration time
+type DocumentDownload struct {
+	DownloadURL string            `json:"downloadUrl"`       // The signed URL for download
+	ExpiresAt   time.Time         `json:"expiresAt"`         // When the URL expires
+	Method      string            `json:"method"`            // HTTP method the URL is signed for
+	Bucket      string            `json:"bucket"`            // Bucket holding the object
+	Object      string            `json:"object"`            // Object key
+	Headers     map[string]string `json:"headers,omitempty"` // Headers the client must send exactly as given
+}
+
+// GenerateSignedDownload generates a signed download for the default bucket
+// Unlike GenerateSignedDownloadURL it returns the expiry and request details with the URL.
+func (u *URLGenerator) GenerateSignedDownload(ctx context.Context, objectName string) (DocumentDownload, error) {
+	return u.GenerateSignedDownloadWithOptions(ctx, u.bucketName, objectName, DownloadOptions{})
+}
+
+// GenerateSignedDownloadWithBucket generates a signed download for a specific bucket
+func (u *URLGenerator) GenerateSignedDownloadWithBucket(ctx context.Context, bucketName, objectName string) (DocumentDownload, error) {
+	return u.GenerateSignedDownloadWithOptions(ctx, bucketName, objectName, DownloadOptions{})
+}
+
+// GenerateSignedDownloadURLWithOptions generates a signed download URL with response header overrides
+func (u *URLGenerator) GenerateSignedDownloadURLWithOptions(ctx context.Context, bucketName, objectName string, options DownloadOptions) (string, error) {
+	download, err := u.GenerateSignedDownloadWithOptions(ctx, bucketName, objectName, options)
+	if err != nil {
+		return "", err
+	}
+	if len(download.Headers) > 0 {
+		return "", fmt.Errorf("download of %s/%s requires encryption key headers; use GenerateSignedDownloadWithOptions", bucketName, objectName)
+	}
+	return download.DownloadURL, nil
+}
+
+// GenerateSignedDownloadWithOptions generates a signed download with response header overrides
+// Disposition and ContentType are signed as response-content-disposition and response-content-type.
+func (u *URLGenerator) GenerateSignedDownloadWithOptions(ctx context.Context, bucketName, objectName string, options DownloadOptions) (DocumentDownload, error) {
+	if err := u.checkObjectKey(objectName); err != nil {
+		return DocumentDownload{}, err
+	}
+	if err := u.authorize(ctx, OperationDownload, bucketName, objectName); err != nil {
+		return DocumentDownload{}, err
+	}
+
+	disposition := options.Disposition
+	if disposition == "" && options.Filename != "" {
+		disposition = DispositionAttachment
+	}
+	if disposition != "" && disposition != DispositionAttachment && disposition != DispositionInline {
+		return DocumentDownload{}, inputErrorf("disposition must be %q or %q, got %q", DispositionAttachment, DispositionInline, disposition)
+	}
+
+	expiry := u.defaultExpiry
+	if options.Expiry > 0 {
+		expiry = options.Expiry
+	}
+	expires := time.Now().Add(expiry)
+
+	opts, err := u.newSignedURLOptions(ctx, "GET", expires)
+	if err != nil {
+		return DocumentDownload{}, err
+	}
+
+	query := url.Values{}
+	if disposition != "" {
+		filename := options.Filename
+		if filename == "" {
+			filename = OriginalFilename(objectName)
+		}
+		query.Set("response-content-disposition", ContentDisposition(disposition, filename))
+	}
+	if options.ContentType != "" {
+		query.Set("response-content-type", options.ContentType)
+	}
+	if len(query) > 0 {
+		opts.QueryParameters = query
+	}
+
+	// Objects written with a customer-supplied key can only be read with it
+	encryptionKey, err := u.encryptionKey(ctx, options.EncryptionKey, bucketName, objectName)
+	if err != nil {
+		return DocumentDownload{}, err
+	}
+	headers := encryptionKey.customerKeyHeaders()
+	applyHeaders(opts, headers)
+
+	signedURL, err := storage.SignedURL(bucketName, objectName, opts)
+	if err != nil {
+		return DocumentDownload{}, fmt.Errorf("failed to generate signed download URL: %w", err)
+	}
+
+	return DocumentDownload{
+		DownloadURL: signedURL,
+		ExpiresAt:   expires,
+		Method:      "GET",
+		Bucket:      bucketName,
+		Object:      objectName,
+		Headers:     headers,
+	}, nil
+}
+
+// OriginalFilename restores the user's file name from a key produced by unique naming
+// Input: "documents/a1b2c3d4_report.pdf" -> Output: "report.pdf"
+// Short UUID, UUID and sortable prefixes are recognized; other keys return their base name unchanged.
+func OriginalFilename(key string) string {
+	base := path.Base(key)
+	if prefix, rest, ok := strings.Cut(base, "_"); ok && rest != "" && isGeneratedID(prefix) {
+		return rest
+	}
+	return base
+}
+
+// ContentDisposition builds an RFC 6266 Content-Disposition header value
+// Names that need an ASCII fallback (non-ASCII, quotes, backslashes, control characters)
+// also get an RFC 5987 UTF-8 filename* parameter carrying the exact name.
+func ContentDisposition(disposition, filename string) string {
+	if filename == "" {
+		return disposition
+	}
+
+	fallback, exact := asciiFilename(filename)
+	value := fmt.Sprintf("%s; filename=\"%s\"", disposition, fallback)
+	if !exact {
+		value += "; filename*=UTF-8''" + encodeRFC5987(filename)
+	}
+	return value
+}
+
+// asciiFilename returns a quoted-string safe ASCII version of name and whether it is unchanged
+func asciiFilename(name string) (string, bool) {
+	exact := true
+	var b strings.Builder
+	for _, r := range name {
+		if r == '"' || r == '\\' || r < 0x20 || r >= 0x7f {
+			b.WriteRune('_')
+			exact = false
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String(), exact
+}
+
+// encodeRFC5987 percent-encodes s as an RFC 5987 ext-value
+func encodeRFC5987(s string) string {
+	const attrChars = "!#$&+-.^_`|~"
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || strings.IndexByte(attrChars, c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
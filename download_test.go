@@ -0,0 +1,186 @@
+package gcsurl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		name        string
+		disposition string
+		filename    string
+		want        string
+	}{
+		{"no file name", "inline", "", "inline"},
+		{"ASCII", "attachment", "report.pdf", `attachment; filename="report.pdf"`},
+		{"non-ASCII", "attachment", "Verträge 2025.pdf", `attachment; filename="Vertr_ge 2025.pdf"; filename*=UTF-8''Vertr%C3%A4ge%202025.pdf`},
+		{"emoji", "inline", "📄.pdf", `inline; filename="_.pdf"; filename*=UTF-8''%F0%9F%93%84.pdf`},
+		{"quotes", "attachment", `say "hi".txt`, `attachment; filename="say _hi_.txt"; filename*=UTF-8''say%20%22hi%22.txt`},
+		{"backslash", "attachment", `a\b.txt`, `attachment; filename="a_b.txt"; filename*=UTF-8''a%5Cb.txt`},
+		{"control character", "attachment", "a\r\nb.txt", `attachment; filename="a__b.txt"; filename*=UTF-8''a%0D%0Ab.txt`},
+		{"delete character", "attachment", "a\x7fb.txt", `attachment; filename="a_b.txt"; filename*=UTF-8''a%7Fb.txt`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ContentDisposition(tt.disposition, tt.filename); got != tt.want {
+				t.Errorf("ContentDisposition(%q, %q) = %s, want %s", tt.disposition, tt.filename, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeRFC5987(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"report.pdf", "report.pdf"},
+		{"!#$&+-.^_`|~", "!#$&+-.^_`|~"},
+		{"a b", "a%20b"},
+		{`'()*%"`, "%27%28%29%2A%25%22"},
+		{"ü", "%C3%BC"},
+		{"日本", "%E6%97%A5%E6%9C%AC"},
+	}
+
+	for _, tt := range tests {
+		if got := encodeRFC5987(tt.value); got != tt.want {
+			t.Errorf("encodeRFC5987(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestOriginalFilename(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"documents/a1b2c3d4_report.pdf", "report.pdf"},
+		{"a1b2c3d4_my_file.pdf", "my_file.pdf"},
+		{"users/1/123e4567-e89b-42d3-a456-426614174000_cv.pdf", "cv.pdf"},
+		{"a1b2c3d4_Verträge 2025.pdf", "Verträge 2025.pdf"},
+		{"A1B2C3D4_report.pdf", "A1B2C3D4_report.pdf"},
+		{"my_report.pdf", "my_report.pdf"},
+		{"a1b2c3d4_", "a1b2c3d4_"},
+		{"reports/q1.pdf", "q1.pdf"},
+	}
+
+	for _, tt := range tests {
+		if got := OriginalFilename(tt.key); got != tt.want {
+			t.Errorf("OriginalFilename(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateSignedDownloadWithOptions(t *testing.T) {
+	signer, err := NewTestSigner("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	generator, err := NewURLGeneratorWithConfig(Config{BucketName: "documents", Signer: signer, DefaultExpiryMinutes: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	customerKey := &EncryptionKey{CustomerKey: bytes.Repeat([]byte{7}, 32)}
+
+	tests := []struct {
+		name            string
+		object          string
+		options         DownloadOptions
+		wantExpiry      time.Duration
+		wantDisposition string
+		wantContentType string
+		wantHeaders     bool
+	}{
+		{"defaults", "reports/q1.pdf", DownloadOptions{}, 10 * time.Minute, "", "", false},
+		{"expiry", "reports/q1.pdf", DownloadOptions{Expiry: time.Minute}, time.Minute, "", "", false},
+		{"attachment with the original name", "reports/a1b2c3d4_Verträge.pdf", DownloadOptions{Disposition: DispositionAttachment}, 10 * time.Minute, `attachment; filename="Vertr_ge.pdf"; filename*=UTF-8''Vertr%C3%A4ge.pdf`, "", false},
+		{"file name implies attachment", "reports/a1b2c3d4_q1.pdf", DownloadOptions{Filename: "Q1.pdf"}, 10 * time.Minute, `attachment; filename="Q1.pdf"`, "", false},
+		{"inline with content type", "images/a1b2c3d4_logo.svg", DownloadOptions{Disposition: DispositionInline, ContentType: "image/svg+xml"}, 10 * time.Minute, `inline; filename="logo.svg"`, "image/svg+xml", false},
+		{"customer-supplied key", "reports/q1.pdf", DownloadOptions{EncryptionKey: customerKey}, 10 * time.Minute, "", "", true},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := time.Now()
+			download, err := generator.GenerateSignedDownloadWithOptions(ctx, "documents", tt.object, tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if download.Method != "GET" || download.Bucket != "documents" || download.Object != tt.object {
+				t.Errorf("download = %+v, want GET documents/%s", download, tt.object)
+			}
+			if expiry := download.ExpiresAt.Sub(before); expiry < tt.wantExpiry || expiry > tt.wantExpiry+time.Second {
+				t.Errorf("ExpiresAt is %v away, want %v", expiry, tt.wantExpiry)
+			}
+
+			signed, err := url.Parse(download.DownloadURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			query := signed.Query()
+			if got := query.Get("response-content-disposition"); got != tt.wantDisposition {
+				t.Errorf("response-content-disposition = %q, want %q", got, tt.wantDisposition)
+			}
+			if got := query.Get("response-content-type"); got != tt.wantContentType {
+				t.Errorf("response-content-type = %q, want %q", got, tt.wantContentType)
+			}
+			// X-Goog-Date has whole seconds, so the signed expiry can be up to a second short
+			seconds, err := strconv.Atoi(query.Get("X-Goog-Expires"))
+			if signedExpiry := time.Duration(seconds) * time.Second; err != nil || signedExpiry > tt.wantExpiry || signedExpiry < tt.wantExpiry-time.Second {
+				t.Errorf("X-Goog-Expires = %q, want %v", query.Get("X-Goog-Expires"), tt.wantExpiry)
+			}
+
+			if tt.wantHeaders != (len(download.Headers) > 0) {
+				t.Errorf("Headers = %v, want headers %v", download.Headers, tt.wantHeaders)
+			}
+			if tt.wantHeaders && download.Headers["x-goog-encryption-algorithm"] != "AES256" {
+				t.Errorf("Headers = %v, want the CSEK headers", download.Headers)
+			}
+		})
+	}
+
+	// The URL-only variant cannot return the headers a CSEK download needs
+	if _, err := generator.GenerateSignedDownloadURLWithOptions(ctx, "documents", "reports/q1.pdf", DownloadOptions{EncryptionKey: customerKey}); err == nil {
+		t.Error("URL-only download with a customer-supplied key: want an error")
+	}
+	_, err = generator.GenerateSignedDownloadWithOptions(ctx, "documents", "reports/q1.pdf", DownloadOptions{Disposition: "download"})
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("unknown disposition: error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestDocumentDownloadJSON(t *testing.T) {
+	download := DocumentDownload{
+		DownloadURL: "https://storage.googleapis.com/documents/q1.pdf?X-Goog-Signature=abc",
+		ExpiresAt:   time.Date(2026, time.October, 16, 12, 0, 0, 0, time.UTC),
+		Method:      "GET",
+		Bucket:      "documents",
+		Object:      "q1.pdf",
+	}
+	data, err := json.Marshal(download)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"downloadUrl":"https://storage.googleapis.com/documents/q1.pdf?X-Goog-Signature=abc","expiresAt":"2026-10-16T12:00:00Z","method":"GET","bucket":"documents","object":"q1.pdf"}`
+	if string(data) != want {
+		t.Errorf("JSON = %s, want %s", data, want)
+	}
+
+	download.Headers = map[string]string{"x-goog-encryption-algorithm": "AES256"}
+	var decoded DocumentDownload
+	if data, err = json.Marshal(download); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.Headers["x-goog-encryption-algorithm"] != "AES256" || !decoded.ExpiresAt.Equal(download.ExpiresAt) {
+		t.Errorf("round trip = %+v, %v, want %+v", decoded, err, download)
+	}
+}
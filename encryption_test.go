@@ -0,0 +1,35 @@
+package gcsurl
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestEncryptionKeyValidate(t *testing.T) {
+	kmsKeyName := "projects/p/locations/l/keyRings/r/cryptoKeys/k"
+	tests := []struct {
+		name    string
+		key     EncryptionKey
+		wantErr bool
+	}{
+		{"customer key", EncryptionKey{CustomerKey: bytes.Repeat([]byte{1}, 32)}, false},
+		{"KMS key", EncryptionKey{KMSKeyName: kmsKeyName}, false},
+		{"empty", EncryptionKey{}, true},
+		{"both", EncryptionKey{CustomerKey: bytes.Repeat([]byte{1}, 32), KMSKeyName: kmsKeyName}, true},
+		{"short customer key", EncryptionKey{CustomerKey: bytes.Repeat([]byte{1}, 16)}, true},
+		{"malformed KMS key name", EncryptionKey{KMSKeyName: "keyRings/r/cryptoKeys/k"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.key.validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidInput) {
+				t.Errorf("validate() error = %v, want ErrInvalidInput", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package gcsurl
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidInput matches errors caused by the caller's input, such as an invalid object name,
+// a disallowed extension or malformed options. Check it with errors.Is, e.g. to answer 400.
+var ErrInvalidInput = errors.New("invalid input")
+
+// inputError marks an error as caused by the caller's input
+type inputError struct {
+	err error
+}
+
+// inputErrorf formats an error that matches ErrInvalidInput; the message is unchanged
+func inputErrorf(format string, args ...any) error {
+	return &inputError{err: fmt.Errorf(format, args...)}
+}
+
+func (e *inputError) Error() string { return e.err.Error() }
+
+func (e *inputError) Unwrap() error { return e.err }
+
+// Is reports whether target is ErrInvalidInput
+func (e *inputError) Is(target error) bool { return target == ErrInvalidInput }
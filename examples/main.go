@@ -279,7 +279,13 @@ type BackupService struct {
 
 func createBackupService() *BackupService {
 	// No restrictions for backup service - accepts any file type
-	gen, err := gcsurl.NewURLGeneratorWithBucket("system-backups")
+	// Backups are rarely read, so they are uploaded straight into the ARCHIVE storage class
+	gen, err := gcsurl.NewURLGeneratorWithConfig(gcsurl.Config{
+		BucketName: "system-backups",
+		BucketStorageOptions: map[string]gcsurl.StorageOptions{
+			"system-backups": {StorageClass: "ARCHIVE"},
+		},
+	})
 	if err != nil {
 		log.Printf("❌ Failed to create backup service: %v", err)
 		return nil
@@ -4,11 +4,13 @@ package gcsurl
 
 import (
 	"context"
-	"crypto/rand"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
-	"path/filepath"
+	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -26,6 +28,16 @@ type URLGenerator struct {
 	bucketName            string
 	defaultExpiry         time.Duration
 	uploadRestrictions    UploadRestrictions
+	signer                Signer
+	authorizeFunc         AuthorizeFunc
+	objectStore           ObjectStore
+	nameStrategy          NameStrategy
+	rootPrefix            string
+	sanitizer             Sanitizer
+	encryptionKeys        EncryptionKeyProvider
+	bucketStorageOptions  map[string]StorageOptions
+	endpoint              *url.URL
+	signedURLClient       *http.Client
 }
 
 // ServiceAccount holds GCP service account credentials
@@ -36,20 +48,27 @@ type ServiceAccount struct {
 
 // DocumentUpload contains the signed upload URL and expiration time
 type DocumentUpload struct {
-	UploadURL    string    `json:"uploadUrl"`    // The signed URL for upload
-	ExpiresAt    time.Time `json:"expiresAt"`    // When the URL expires
-	GeneratedKey string    `json:"generatedKey"` // Unique file path for storage
-	OriginalName string    `json:"originalName"` // Original file name provided by user
+	UploadURL    string            `json:"uploadUrl"`          // The signed URL for upload
+	ExpiresAt    time.Time         `json:"expiresAt"`          // When the URL expires
+	GeneratedKey string            `json:"generatedKey"`       // Unique file path for storage
+	OriginalName string            `json:"originalName"`       // Original file name provided by user
+	Headers      map[string]string `json:"headers,omitempty"`  // Headers the client must send exactly as given
+	Checksum     *Checksum         `json:"checksum,omitempty"` // Hashes the upload is bound to, for VerifyChecksum
 }
 
 // UploadRestrictions holds upload validation rules
 type UploadRestrictions struct {
-	AllowMultiple     bool     `json:"allowMultiple"`
+	AllowMultiple     bool     `json:"allowMultiple"` // When false, every upload URL can be used once
 	AllowedExtensions []string `json:"allowedExtensions"`
 	MaxFileSizeMB     int64    `json:"maxFileSizeMB"`
 	MaxFileSizeBytes  int64    `json:"maxFileSizeBytes"`
+	MinFileSizeBytes  int64    `json:"minFileSizeBytes"`
+	AllowedMIMETypes  []string `json:"allowedMimeTypes"` // Checked by VerifyUpload; "image/*" wildcards allowed
 }
 
+// maxObjectSizeBytes is the largest object GCS accepts (5 TiB)
+const maxObjectSizeBytes = 5 * 1024 * 1024 * 1024 * 1024
+
 // Config holds configuration for the URLGenerator
 type Config struct {
 	ProjectID             string
@@ -57,6 +76,51 @@ type Config struct {
 	ServiceAccountKeyPath string
 	DefaultExpiryMinutes  int
 	UploadRestrictions    *UploadRestrictions
+
+	// Signer signs URLs instead of the service account key or IAM signBlob
+	Signer Signer
+	// SigningServiceAccountEmail is the service account used for IAM signBlob signing
+	// when no private key is loaded. Discovered from the runtime when empty.
+	SigningServiceAccountEmail string
+	// IAMCredentialsEndpoint overrides the IAM Credentials API base URL (e.g. for a local test server)
+	IAMCredentialsEndpoint string
+	// HTTPClient is used for signBlob calls instead of a client built from default credentials
+	// It is not used for requests to signed URLs; see SignedURLClient.
+	HTTPClient *http.Client
+
+	// Authorize is consulted before signing any upload, download, DELETE or HEAD URL; nil allows everything
+	// Uploads are checked against the generated key, inside the root prefix.
+	Authorize AuthorizeFunc
+
+	// ObjectStore reads uploaded objects for VerifyUpload; the storage client is used when nil
+	ObjectStore ObjectStore
+
+	// NameStrategy builds object keys from requested names (default: ShortUUIDNames)
+	NameStrategy NameStrategy
+	// KeyTemplate builds object keys from a template such as "{tenant}/{yyyy}/{mm}/{id}{ext}"
+	// It is validated here and cannot be combined with NameStrategy. File names are cleaned with
+	// FilenameSanitizer{} unless Sanitizer is set.
+	KeyTemplate string
+	// RootPrefix is prepended to every upload key, which can never escape it (e.g. "tenants/acme/")
+	// Download, delete and HEAD URLs are only signed for keys inside it.
+	RootPrefix string
+	// Sanitizer cleans file names before unique naming, e.g. FilenameSanitizer{}; names are kept as-is when nil
+	Sanitizer Sanitizer
+
+	// EncryptionKeys returns CSEK or CMEK keys per object, e.g. per tenant; nil means Google-managed keys
+	EncryptionKeys EncryptionKeyProvider
+
+	// BucketStorageOptions are signed into every upload URL for a bucket, keyed by bucket name
+	// e.g. {"system-backups": {StorageClass: "ARCHIVE"}}; they are validated here.
+	BucketStorageOptions map[string]StorageOptions
+
+	// Endpoint replaces https://storage.googleapis.com in signed URLs and POST policies,
+	// e.g. the URL of a gcsurltest.Server or an emulator. http endpoints produce http URLs.
+	Endpoint string
+	// SignedURLClient sends the requests the generator makes to its own signed URLs: starting
+	// resumable sessions, multipart initiation, completion and abort, and slot reservation
+	// (default: http.DefaultClient). The URLs carry their signature, so it must not add credentials.
+	SignedURLClient *http.Client
 }
 
 // NewURLGenerator creates a new URLGenerator instance
@@ -66,6 +130,12 @@ type Config struct {
 // - GCS_SERVICE_ACCOUNT_JSON: Service account JSON as string (preferred)
 // - GOOGLE_APPLICATION_CREDENTIALS: Path to service account JSON file (fallback)
 // - GCS_DEFAULT_EXPIRY_MINUTES: Default expiry time in minutes (default: 15)
+// - GCS_SIGNING_SERVICE_ACCOUNT: Service account email for IAM signBlob signing (optional)
+// - GCS_IAM_CREDENTIALS_ENDPOINT: IAM Credentials API base URL (optional)
+// - GCS_ROOT_PREFIX: Prefix every upload key is kept under (optional)
+// - GCS_ENDPOINT: Storage endpoint signed URLs point at instead of storage.googleapis.com (optional)
+//
+// When no private key is available (Workload Identity), URLs are signed via the IAM signBlob API.
 func NewURLGenerator() (*URLGenerator, error) {
 	return NewURLGeneratorWithRestrictions(nil)
 }
@@ -113,6 +183,19 @@ func NewURLGeneratorWithRestrictions(restrictions *UploadRestrictions) (*URLGene
 	if restrictions != nil {
 		uploadRestrictions = *restrictions
 	}
+	if err := uploadRestrictions.validate(); err != nil {
+		return nil, err
+	}
+
+	rootPrefix, err := normalizeRootPrefix(os.Getenv("GCS_ROOT_PREFIX"))
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, err := normalizeEndpoint(os.Getenv("GCS_ENDPOINT"))
+	if err != nil {
+		return nil, err
+	}
 	
 	var svcAccount *ServiceAccount
 	var svcAccountJSON []byte
@@ -142,7 +225,11 @@ func NewURLGeneratorWithRestrictions(restrictions *UploadRestrictions) (*URLGene
 	}
 
 	// For Workload Identity or default credentials, svcAccount can be nil
-	// The client will use default credentials automatically
+	// The client will use default credentials automatically, and URLs are signed via IAM signBlob
+	signer, err := newDefaultSigner(svcAccount, os.Getenv("GCS_SIGNING_SERVICE_ACCOUNT"), os.Getenv("GCS_IAM_CREDENTIALS_ENDPOINT"), nil)
+	if err != nil {
+		return nil, err
+	}
 
 	return &URLGenerator{
 		serviceAccountKeyPath: serviceAccountKeyPath,
@@ -152,6 +239,9 @@ func NewURLGeneratorWithRestrictions(restrictions *UploadRestrictions) (*URLGene
 		bucketName:            bucketName,
 		defaultExpiry:         defaultExpiry,
 		uploadRestrictions:    uploadRestrictions,
+		signer:                signer,
+		rootPrefix:            rootPrefix,
+		endpoint:              endpoint,
 	}, nil
 }
 
@@ -181,6 +271,52 @@ func NewURLGeneratorWithConfig(config Config) (*URLGenerator, error) {
 	if config.UploadRestrictions != nil {
 		uploadRestrictions = *config.UploadRestrictions
 	}
+	if err := uploadRestrictions.validate(); err != nil {
+		return nil, err
+	}
+
+	// Key templates are a naming strategy, validated before any request is made
+	nameStrategy := config.NameStrategy
+	sanitizer := config.Sanitizer
+	if config.KeyTemplate != "" {
+		if nameStrategy != nil {
+			return nil, fmt.Errorf("KeyTemplate and NameStrategy cannot be combined")
+		}
+		keyTemplate, err := NewKeyTemplate(config.KeyTemplate)
+		if err != nil {
+			return nil, err
+		}
+		nameStrategy = keyTemplate
+		// Template keys are built from file names, so they are cleaned unless a sanitizer is configured
+		if sanitizer == nil {
+			sanitizer = FilenameSanitizer{}
+		}
+	}
+
+	// Root prefix hierarchy: Config.RootPrefix > GCS_ROOT_PREFIX env var
+	rootPrefix := config.RootPrefix
+	if rootPrefix == "" {
+		rootPrefix = os.Getenv("GCS_ROOT_PREFIX")
+	}
+	rootPrefix, err := normalizeRootPrefix(rootPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	bucketStorageOptions, err := normalizeBucketStorageOptions(config.BucketStorageOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	// Endpoint hierarchy: Config.Endpoint > GCS_ENDPOINT env var > storage.googleapis.com
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = os.Getenv("GCS_ENDPOINT")
+	}
+	endpointURL, err := normalizeEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
 
 	var svcAccount *ServiceAccount
 	var svcAccountJSON []byte
@@ -206,6 +342,25 @@ func NewURLGeneratorWithConfig(config Config) (*URLGenerator, error) {
 		svcAccountJSON = data
 	}
 
+	// Signer hierarchy: Config.Signer > service account key > IAM signBlob
+	signer := config.Signer
+	if signer == nil {
+		// IAM signBlob settings hierarchy: Config > environment variables > runtime discovery
+		signingEmail := config.SigningServiceAccountEmail
+		if signingEmail == "" {
+			signingEmail = os.Getenv("GCS_SIGNING_SERVICE_ACCOUNT")
+		}
+		iamEndpoint := config.IAMCredentialsEndpoint
+		if iamEndpoint == "" {
+			iamEndpoint = os.Getenv("GCS_IAM_CREDENTIALS_ENDPOINT")
+		}
+		var err error
+		signer, err = newDefaultSigner(svcAccount, signingEmail, iamEndpoint, config.HTTPClient)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &URLGenerator{
 		serviceAccountKeyPath: config.ServiceAccountKeyPath,
 		svcAccount:            svcAccount,
@@ -214,105 +369,128 @@ func NewURLGeneratorWithConfig(config Config) (*URLGenerator, error) {
 		bucketName:            bucketName,
 		defaultExpiry:         defaultExpiry,
 		uploadRestrictions:    uploadRestrictions,
+		signer:                signer,
+		authorizeFunc:         config.Authorize,
+		objectStore:           config.ObjectStore,
+		nameStrategy:          nameStrategy,
+		rootPrefix:            rootPrefix,
+		sanitizer:             sanitizer,
+		encryptionKeys:        config.EncryptionKeys,
+		bucketStorageOptions:  bucketStorageOptions,
+		endpoint:              endpointURL,
+		signedURLClient:       config.SignedURLClient,
 	}, nil
 }
 
-// getServiceAccount returns the loaded service account or an error
-func (u *URLGenerator) getServiceAccount() (ServiceAccount, error) {
-	if u.svcAccount != nil {
-		return *u.svcAccount, nil
+// newSignedURLOptions returns V4 signed URL options with the configured signer applied
+// V4 signs every header in opts.Headers, so clients must send them exactly as returned.
+func (u *URLGenerator) newSignedURLOptions(ctx context.Context, method string, expires time.Time) (*storage.SignedURLOptions, error) {
+	if u.signer == nil {
+		return nil, fmt.Errorf("service account not loaded - configure GCS_SERVICE_ACCOUNT_JSON or GOOGLE_APPLICATION_CREDENTIALS")
+	}
+
+	accessID, signBytes, err := signingFunc(ctx, u.signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve signing credentials: %w", err)
 	}
-	return ServiceAccount{}, fmt.Errorf("service account not loaded - configure GCS_SERVICE_ACCOUNT_JSON or GOOGLE_APPLICATION_CREDENTIALS")
+	opts := &storage.SignedURLOptions{
+		Method:         method,
+		Expires:        expires,
+		Scheme:         storage.SigningSchemeV4,
+		GoogleAccessID: accessID,
+		SignBytes:      signBytes,
+	}
+	if u.endpoint != nil {
+		opts.Hostname = u.endpoint.Host
+		opts.Insecure = u.endpoint.Scheme == "http"
+	}
+	return opts, nil
 }
 
-// GenerateSignedUploadURL generates a signed URL for uploading a file to GCS with unique naming
-// The URL expires after the configured default time. If upload restrictions are configured,
-// they will be automatically applied (validation + content-type detection + size limits).
-// Automatically generates unique object names to prevent collisions while preserving directory structure.
-func (u *URLGenerator) GenerateSignedUploadURL(ctx context.Context, objectName string) (DocumentUpload, error) {
-	// Generate unique object name
-	uniqueObjectName, err := u.generateUniqueObjectName(objectName)
+// normalizeEndpoint validates a storage endpoint such as "http://127.0.0.1:4443"
+func normalizeEndpoint(endpoint string) (*url.URL, error) {
+	if endpoint == "" {
+		return nil, nil
+	}
+	parsed, err := url.Parse(endpoint)
 	if err != nil {
-		return DocumentUpload{}, fmt.Errorf("failed to generate unique object name: %w", err)
+		return nil, fmt.Errorf("invalid storage endpoint: %w", err)
 	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" || parsed.Host == "" || strings.Trim(parsed.Path, "/") != "" || parsed.RawQuery != "" {
+		return nil, fmt.Errorf("storage endpoint %q must be an http or https URL without a path", endpoint)
+	}
+	return parsed, nil
+}
 
-	// Apply validation if restrictions are configured
-	if u.hasRestrictions() {
-		if err := u.ValidateUpload(objectName); err != nil {
-			return DocumentUpload{}, err
+// applyHeaders adds required request headers to the signed URL options
+// Content-Type and Content-MD5 go in their dedicated fields; everything else is signed as an extension header.
+func applyHeaders(opts *storage.SignedURLOptions, headers map[string]string) {
+	for name, value := range headers {
+		if strings.EqualFold(name, "Content-Type") {
+			opts.ContentType = value
+			continue
 		}
-		upload, err := u.generateUploadURLWithRestrictions(ctx, u.bucketName, uniqueObjectName, u.defaultExpiry)
-		if err != nil {
-			return DocumentUpload{}, err
+		if strings.EqualFold(name, "Content-MD5") {
+			opts.MD5 = value
+			continue
 		}
-		upload.GeneratedKey = uniqueObjectName
-		upload.OriginalName = objectName
-		return upload, nil
+		opts.Headers = append(opts.Headers, name+":"+value)
 	}
+	sort.Strings(opts.Headers)
+}
 
-	// No restrictions - use simple generation
-	upload, err := u.GenerateSignedUploadURLWithExpiry(ctx, u.bucketName, uniqueObjectName, u.defaultExpiry)
-	if err != nil {
-		return DocumentUpload{}, err
-	}
-	upload.GeneratedKey = uniqueObjectName
-	upload.OriginalName = objectName
-	return upload, nil
+// GenerateSignedUploadURL generates a signed URL for uploading a file to GCS with unique naming
+// The URL expires after the configured default time. If upload restrictions are configured,
+// they will be automatically applied (validation + content-type detection + size limits).
+// Automatically generates unique object names to prevent collisions while preserving directory structure.
+func (u *URLGenerator) GenerateSignedUploadURL(ctx context.Context, objectName string) (DocumentUpload, error) {
+	return u.GenerateSignedUploadURLWithOptions(ctx, u.bucketName, objectName, UploadOptions{})
 }
 
 // GenerateSignedUploadURLWithBucket generates a signed URL for uploading to a specific bucket with unique naming
 // If upload restrictions are configured, they will be automatically applied.
 // Automatically generates unique object names to prevent collisions while preserving directory structure.
 func (u *URLGenerator) GenerateSignedUploadURLWithBucket(ctx context.Context, bucketName, objectName string) (DocumentUpload, error) {
-	// Generate unique object name
-	uniqueObjectName, err := u.generateUniqueObjectName(objectName)
+	return u.GenerateSignedUploadURLWithOptions(ctx, bucketName, objectName, UploadOptions{})
+}
+
+// GenerateSignedUploadURLWithExpiry generates a signed URL for uploading with custom expiry
+// This method does NOT generate unique names - it uses the exact objectName provided.
+// Use this when you want to overwrite existing files or when you manage naming yourself.
+func (u *URLGenerator) GenerateSignedUploadURLWithExpiry(ctx context.Context, bucketName, objectName string, expiry time.Duration) (DocumentUpload, error) {
+	key, err := u.uploadKey(objectName, "", nil, true)
 	if err != nil {
-		return DocumentUpload{}, fmt.Errorf("failed to generate unique object name: %w", err)
+		return DocumentUpload{}, err
 	}
-
-	// Apply validation if restrictions are configured
-	if u.hasRestrictions() {
-		if err := u.ValidateUpload(objectName); err != nil {
-			return DocumentUpload{}, err
-		}
-		upload, err := u.generateUploadURLWithRestrictions(ctx, bucketName, uniqueObjectName, u.defaultExpiry)
-		if err != nil {
-			return DocumentUpload{}, err
-		}
-		upload.GeneratedKey = uniqueObjectName
-		upload.OriginalName = objectName
-		return upload, nil
+	if err := u.authorize(ctx, OperationUpload, bucketName, key); err != nil {
+		return DocumentUpload{}, err
 	}
 
-	// No restrictions - use simple generation
-	upload, err := u.GenerateSignedUploadURLWithExpiry(ctx, bucketName, uniqueObjectName, u.defaultExpiry)
+	headers := map[string]string{"Content-Type": "application/octet-stream"}
+	storageOptions, err := u.storageOptions(bucketName, StorageOptions{})
 	if err != nil {
 		return DocumentUpload{}, err
 	}
-	upload.GeneratedKey = uniqueObjectName
-	upload.OriginalName = objectName
-	return upload, nil
-}
+	for name, value := range storageOptions.headers() {
+		headers[name] = value
+	}
 
-// GenerateSignedUploadURLWithExpiry generates a signed URL for uploading with custom expiry
-// This method does NOT generate unique names - it uses the exact objectName provided.
-// Use this when you want to overwrite existing files or when you manage naming yourself.
-func (u *URLGenerator) GenerateSignedUploadURLWithExpiry(ctx context.Context, bucketName, objectName string, expiry time.Duration) (DocumentUpload, error) {
-	sa, err := u.getServiceAccount()
+	encryptionKey, err := u.encryptionKey(ctx, nil, bucketName, key)
 	if err != nil {
 		return DocumentUpload{}, err
 	}
+	for name, value := range encryptionKey.uploadHeaders() {
+		headers[name] = value
+	}
 
 	expires := time.Now().Add(expiry)
-	opts := &storage.SignedURLOptions{
-		Method:         "PUT",
-		Expires:        expires,
-		ContentType:    "application/octet-stream",
-		GoogleAccessID: sa.ClientEmail,
-		PrivateKey:     []byte(sa.PrivateKey),
+	opts, err := u.newSignedURLOptions(ctx, "PUT", expires)
+	if err != nil {
+		return DocumentUpload{}, err
 	}
+	applyHeaders(opts, headers)
 
-	signedURL, err := storage.SignedURL(bucketName, objectName, opts)
+	signedURL, err := storage.SignedURL(bucketName, key, opts)
 	if err != nil {
 		return DocumentUpload{}, fmt.Errorf("failed to generate signed upload URL: %w", err)
 	}
@@ -320,8 +498,9 @@ func (u *URLGenerator) GenerateSignedUploadURLWithExpiry(ctx context.Context, bu
 	return DocumentUpload{
 		UploadURL:    signedURL,
 		ExpiresAt:    expires,
-		GeneratedKey: objectName, // Same as original when no unique naming
+		GeneratedKey: key, // Same as original (under the root prefix) when no unique naming
 		OriginalName: objectName,
+		Headers:      headers,
 	}, nil
 }
 
@@ -338,25 +517,7 @@ func (u *URLGenerator) GenerateSignedDownloadURLWithBucket(ctx context.Context,
 
 // GenerateSignedDownloadURLWithExpiry generates a signed URL for downloading with custom expiry
 func (u *URLGenerator) GenerateSignedDownloadURLWithExpiry(ctx context.Context, bucketName, objectName string, expiry time.Duration) (string, error) {
-	sa, err := u.getServiceAccount()
-	if err != nil {
-		return "", err
-	}
-
-	expires := time.Now().Add(expiry)
-	opts := &storage.SignedURLOptions{
-		Method:         "GET",
-		Expires:        expires,
-		GoogleAccessID: sa.ClientEmail,
-		PrivateKey:     []byte(sa.PrivateKey),
-	}
-
-	signedURL, err := storage.SignedURL(bucketName, objectName, opts)
-	if err != nil {
-		return "", fmt.Errorf("failed to generate signed download URL: %w", err)
-	}
-
-	return signedURL, nil
+	return u.GenerateSignedDownloadURLWithOptions(ctx, bucketName, objectName, DownloadOptions{Expiry: expiry})
 }
 
 // CreateStorageClient creates a GCS client for advanced operations
@@ -388,6 +549,19 @@ func (u *URLGenerator) GetProjectID() string {
 	return u.projectID
 }
 
+// GetSigner returns the signer used for URLs and policies
+func (u *URLGenerator) GetSigner() Signer {
+	return u.signer
+}
+
+// GetNameStrategy returns the naming strategy used for object keys
+func (u *URLGenerator) GetNameStrategy() NameStrategy {
+	if u.nameStrategy == nil {
+		return ShortUUIDNames()
+	}
+	return u.nameStrategy
+}
+
 // GetDefaultExpiry returns the configured default expiry duration
 func (u *URLGenerator) GetDefaultExpiry() time.Duration {
 	return u.defaultExpiry
@@ -431,8 +605,24 @@ func NewUploadRestrictionsFromEnv() *UploadRestrictions {
 		}
 	}
 
+	// Parse min file size
+	if minSizeStr := os.Getenv("GCS_MIN_FILE_SIZE_BYTES"); minSizeStr != "" {
+		if minSize, err := strconv.ParseInt(minSizeStr, 10, 64); err == nil && minSize > 0 {
+			restrictions.MinFileSizeBytes = minSize
+		}
+	}
+
+	// Parse allowed MIME types for post-upload verification
+	if mimeTypes := os.Getenv("GCS_ALLOWED_MIME_TYPES"); mimeTypes != "" {
+		for _, mimeType := range strings.Split(mimeTypes, ",") {
+			if mimeType = strings.ToLower(strings.TrimSpace(mimeType)); mimeType != "" {
+				restrictions.AllowedMIMETypes = append(restrictions.AllowedMIMETypes, mimeType)
+			}
+		}
+	}
+
 	// Only return restrictions if at least one was configured
-	if !restrictions.AllowMultiple || len(restrictions.AllowedExtensions) > 0 || restrictions.MaxFileSizeMB > 0 {
+	if !restrictions.AllowMultiple || len(restrictions.AllowedExtensions) > 0 || restrictions.MaxFileSizeMB > 0 || restrictions.MinFileSizeBytes > 0 || len(restrictions.AllowedMIMETypes) > 0 {
 		return restrictions
 	}
 	return nil
@@ -442,7 +632,7 @@ func NewUploadRestrictionsFromEnv() *UploadRestrictions {
 func (u *URLGenerator) ValidateUpload(filename string) error {
 	// Check file extension if restrictions are set
 	if len(u.uploadRestrictions.AllowedExtensions) > 0 {
-		ext := strings.ToLower(filepath.Ext(filename))
+		ext := strings.ToLower(path.Ext(filename))
 		allowed := false
 		for _, allowedExt := range u.uploadRestrictions.AllowedExtensions {
 			if ext == allowedExt {
@@ -451,7 +641,7 @@ func (u *URLGenerator) ValidateUpload(filename string) error {
 			}
 		}
 		if !allowed {
-			return fmt.Errorf("file extension %s not allowed. Allowed extensions: %v", ext, u.uploadRestrictions.AllowedExtensions)
+			return inputErrorf("file extension %s not allowed. Allowed extensions: %v", ext, u.uploadRestrictions.AllowedExtensions)
 		}
 	}
 
@@ -459,46 +649,21 @@ func (u *URLGenerator) ValidateUpload(filename string) error {
 }
 
 
-// generateUploadURLWithRestrictions generates upload URL applying all restrictions
-func (u *URLGenerator) generateUploadURLWithRestrictions(ctx context.Context, bucketName, objectName string, expiry time.Duration) (DocumentUpload, error) {
-	sa, err := u.getServiceAccount()
-	if err != nil {
-		return DocumentUpload{}, err
-	}
-
-	expires := time.Now().Add(expiry)
-
-	// Build headers based on restrictions
-	var headers []string
-	if u.uploadRestrictions.MaxFileSizeBytes > 0 {
-		headers = append(headers, fmt.Sprintf("Content-Length:%d", u.uploadRestrictions.MaxFileSizeBytes))
-	}
-
+// restrictedUploadHeaders returns the headers signed into an upload URL under restrictions
+func (u *URLGenerator) restrictedUploadHeaders(objectName string) map[string]string {
 	// Determine content type based on file extension
 	contentType := "application/octet-stream"
-	if ext := strings.ToLower(filepath.Ext(objectName)); ext != "" {
+	if ext := strings.ToLower(path.Ext(objectName)); ext != "" {
 		contentType = getContentTypeFromExtension(ext)
 	}
 
-	opts := &storage.SignedURLOptions{
-		Method:         "PUT",
-		Expires:        expires,
-		ContentType:    contentType,
-		Headers:        headers,
-		GoogleAccessID: sa.ClientEmail,
-		PrivateKey:     []byte(sa.PrivateKey),
-	}
-
-	signedURL, err := storage.SignedURL(bucketName, objectName, opts)
-	if err != nil {
-		return DocumentUpload{}, fmt.Errorf("failed to generate validated upload URL: %w", err)
+	// Build headers based on restrictions
+	// GCS rejects the upload unless its size is within x-goog-content-length-range
+	headers := map[string]string{"Content-Type": contentType}
+	if minSize, maxSize, ok := u.uploadRestrictions.contentLengthRange(); ok {
+		headers["x-goog-content-length-range"] = fmt.Sprintf("%d,%d", minSize, maxSize)
 	}
-
-	return DocumentUpload{
-		UploadURL: signedURL,
-		ExpiresAt: expires,
-		// GeneratedKey and OriginalName will be set by the calling function
-	}, nil
+	return headers
 }
 
 // getContentTypeFromExtension returns the appropriate content type for a file extension
@@ -539,75 +704,51 @@ func (u *URLGenerator) GetUploadRestrictions() UploadRestrictions {
 func (u *URLGenerator) hasRestrictions() bool {
 	return len(u.uploadRestrictions.AllowedExtensions) > 0 || 
 		u.uploadRestrictions.MaxFileSizeMB > 0 || 
+		u.uploadRestrictions.MaxFileSizeBytes > 0 ||
+		u.uploadRestrictions.MinFileSizeBytes > 0 ||
 		!u.uploadRestrictions.AllowMultiple
 }
 
-// HasUploadRestrictions returns true if upload restrictions are configured
-func (u *URLGenerator) HasUploadRestrictions() bool {
-	return u.hasRestrictions()
+// maxFileSize returns the maximum upload size in bytes, or 0 when unlimited
+// MaxFileSizeBytes wins; otherwise it is derived from MaxFileSizeMB.
+func (r UploadRestrictions) maxFileSize() int64 {
+	if r.MaxFileSizeBytes > 0 {
+		return r.MaxFileSizeBytes
+	}
+	return r.MaxFileSizeMB * 1024 * 1024
 }
 
-// generateUniqueObjectName generates a unique object name while preserving directory structure
-// Input: "documents/file.pdf" -> Output: "documents/a1b2c3d4_file.pdf"
-// Input: "file.pdf" -> Output: "a1b2c3d4_file.pdf"
-func (u *URLGenerator) generateUniqueObjectName(originalPath string) (string, error) {
-	// Generate UUID-like identifier (8 chars)
-	uuid, err := generateShortUUID()
-	if err != nil {
-		return "", fmt.Errorf("failed to generate UUID: %w", err)
+// contentLengthRange returns the allowed upload size range in bytes
+// ok is false when no size restriction is configured.
+func (r UploadRestrictions) contentLengthRange() (minSize, maxSize int64, ok bool) {
+	maxSize = r.maxFileSize()
+	if maxSize <= 0 && r.MinFileSizeBytes <= 0 {
+		return 0, 0, false
 	}
-
-	// Split path into directory and filename
-	dir := filepath.Dir(originalPath)
-	filename := filepath.Base(originalPath)
-
-	// Split filename into name and extension
-	ext := filepath.Ext(filename)
-	nameWithoutExt := strings.TrimSuffix(filename, ext)
-
-	// Create unique filename: uuid_originalname.ext
-	uniqueFilename := fmt.Sprintf("%s_%s%s", uuid, nameWithoutExt, ext)
-
-	// Reconstruct full path
-	if dir == "." {
-		// No directory, just return unique filename
-		return uniqueFilename, nil
+	if maxSize <= 0 {
+		maxSize = maxObjectSizeBytes
 	}
-
-	// Combine directory with unique filename
-	return filepath.Join(dir, uniqueFilename), nil
+	return r.MinFileSizeBytes, maxSize, true
 }
 
-// generateShortUUID generates a short UUID-like string (8 characters)
-func generateShortUUID() (string, error) {
-	bytes := make([]byte, 4) // 4 bytes = 8 hex characters
-	if _, err := rand.Read(bytes); err != nil {
-		return "", err
+// validate checks that the restrictions are consistent
+func (r UploadRestrictions) validate() error {
+	if r.MaxFileSizeMB < 0 || r.MaxFileSizeBytes < 0 || r.MinFileSizeBytes < 0 {
+		return fmt.Errorf("upload size limits cannot be negative")
 	}
-	return fmt.Sprintf("%x", bytes), nil
+	if maxSize := r.maxFileSize(); maxSize > 0 && r.MinFileSizeBytes > maxSize {
+		return fmt.Errorf("minimum file size %d bytes exceeds maximum file size %d bytes", r.MinFileSizeBytes, maxSize)
+	}
+	return nil
+}
+
+// HasUploadRestrictions returns true if upload restrictions are configured
+func (u *URLGenerator) HasUploadRestrictions() bool {
+	return u.hasRestrictions()
 }
 
 // GenerateSignedUploadURLWithOriginalName generates a signed URL using the original object name
 // This method does NOT generate unique names - use this when you want to overwrite existing files
 func (u *URLGenerator) GenerateSignedUploadURLWithOriginalName(ctx context.Context, objectName string) (DocumentUpload, error) {
-	// Apply validation if restrictions are configured
-	if u.hasRestrictions() {
-		if err := u.ValidateUpload(objectName); err != nil {
-			return DocumentUpload{}, err
-		}
-		upload, err := u.generateUploadURLWithRestrictions(ctx, u.bucketName, objectName, u.defaultExpiry)
-		if err != nil {
-			return DocumentUpload{}, err
-		}
-		upload.GeneratedKey = objectName
-		upload.OriginalName = objectName
-		return upload, nil
-	}
-
-	// No restrictions - use simple generation
-	upload, err := u.GenerateSignedUploadURLWithExpiry(ctx, u.bucketName, objectName, u.defaultExpiry)
-	if err != nil {
-		return DocumentUpload{}, err
-	}
-	return upload, nil
+	return u.GenerateSignedUploadURLWithOptions(ctx, u.bucketName, objectName, UploadOptions{UseOriginalName: true})
 }
\ No newline at end of file
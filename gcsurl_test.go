@@ -0,0 +1,108 @@
+package gcsurl
+
+import "testing"
+
+func TestContentLengthRange(t *testing.T) {
+	tests := []struct {
+		name         string
+		restrictions UploadRestrictions
+		wantMin      int64
+		wantMax      int64
+		wantOK       bool
+	}{
+		{"no limits", UploadRestrictions{}, 0, 0, false},
+		{"maximum in MB", UploadRestrictions{MaxFileSizeMB: 2}, 0, 2 * 1024 * 1024, true},
+		{"maximum in bytes wins", UploadRestrictions{MaxFileSizeMB: 2, MaxFileSizeBytes: 1000}, 0, 1000, true},
+		{"minimum only", UploadRestrictions{MinFileSizeBytes: 10}, 10, maxObjectSizeBytes, true},
+		{"both", UploadRestrictions{MinFileSizeBytes: 10, MaxFileSizeBytes: 1000}, 10, 1000, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			minSize, maxSize, ok := tt.restrictions.contentLengthRange()
+			if minSize != tt.wantMin || maxSize != tt.wantMax || ok != tt.wantOK {
+				t.Errorf("contentLengthRange() = %d, %d, %v, want %d, %d, %v", minSize, maxSize, ok, tt.wantMin, tt.wantMax, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestUploadRestrictionsValidate(t *testing.T) {
+	tests := []struct {
+		name         string
+		restrictions UploadRestrictions
+		wantErr      bool
+	}{
+		{"no limits", UploadRestrictions{}, false},
+		{"minimum below maximum", UploadRestrictions{MinFileSizeBytes: 10, MaxFileSizeMB: 1}, false},
+		{"minimum equals maximum", UploadRestrictions{MinFileSizeBytes: 1000, MaxFileSizeBytes: 1000}, false},
+		{"minimum without maximum", UploadRestrictions{MinFileSizeBytes: 1 << 40}, false},
+		{"minimum above maximum", UploadRestrictions{MinFileSizeBytes: 1001, MaxFileSizeBytes: 1000}, true},
+		{"negative minimum", UploadRestrictions{MinFileSizeBytes: -1}, true},
+		{"negative maximum", UploadRestrictions{MaxFileSizeMB: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.restrictions.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRestrictedUploadHeaders(t *testing.T) {
+	tests := []struct {
+		name         string
+		restrictions UploadRestrictions
+		want         string // x-goog-content-length-range; "" means not signed
+	}{
+		{"extensions only", UploadRestrictions{AllowedExtensions: []string{".pdf"}}, ""},
+		{"maximum", UploadRestrictions{MaxFileSizeMB: 1}, "0,1048576"},
+		{"minimum", UploadRestrictions{MinFileSizeBytes: 100}, "100,5497558138880"},
+		{"both", UploadRestrictions{MinFileSizeBytes: 100, MaxFileSizeBytes: 2048}, "100,2048"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator := &URLGenerator{uploadRestrictions: tt.restrictions}
+			headers := generator.restrictedUploadHeaders("reports/q1.pdf")
+			if got, ok := headers["x-goog-content-length-range"]; got != tt.want || ok != (tt.want != "") {
+				t.Errorf("x-goog-content-length-range = %q, want %q", got, tt.want)
+			}
+			if headers["Content-Type"] != "application/pdf" {
+				t.Errorf("Content-Type = %q, want application/pdf", headers["Content-Type"])
+			}
+		})
+	}
+}
+
+func TestNewUploadRestrictionsFromEnvSizes(t *testing.T) {
+	tests := []struct {
+		minBytes string
+		maxMB    string
+		wantMin  int64
+		wantMax  int64
+	}{
+		{"", "", 0, 0},
+		{"100", "2", 100, 2 * 1024 * 1024},
+		{"-5", "0", 0, 0},
+		{"abc", "ten", 0, 0},
+	}
+
+	for _, name := range []string{"GCS_ALLOW_MULTIPLE_UPLOADS", "GCS_ALLOWED_FILE_EXTENSIONS", "GCS_ALLOWED_MIME_TYPES"} {
+		t.Setenv(name, "")
+	}
+	for _, tt := range tests {
+		t.Setenv("GCS_MIN_FILE_SIZE_BYTES", tt.minBytes)
+		t.Setenv("GCS_MAX_FILE_SIZE_MB", tt.maxMB)
+		// No restrictions at all are reported as nil
+		restrictions := NewUploadRestrictionsFromEnv()
+		if restrictions == nil {
+			restrictions = &UploadRestrictions{}
+		}
+		if restrictions.MinFileSizeBytes != tt.wantMin || restrictions.maxFileSize() != tt.wantMax {
+			t.Errorf("min %q, max %q: got %d-%d bytes, want %d-%d", tt.minBytes, tt.maxMB, restrictions.MinFileSizeBytes, restrictions.maxFileSize(), tt.wantMin, tt.wantMax)
+		}
+	}
+}
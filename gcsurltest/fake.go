@@ -0,0 +1,255 @@
+package gcsurltest
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tropikoearth/gcsurl"
+	"github.com/tropikoearth/gcsurl/internal/hooks"
+)
+
+// fakeEpoch is the default time a FakeGenerator issues URLs at
+var fakeEpoch = time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Call is a recorded FakeGenerator call
+type Call struct {
+	Method  string // URLGenerator method name, e.g. "GenerateSignedUploadURLWithOptions"
+	Bucket  string // Bucket passed or defaulted
+	Object  string // Object name as passed
+	Options any    // UploadOptions, DownloadOptions, Checksum or expiry passed with the call, if any
+}
+
+// FakeGenerator is a gcsurl.URLSigner that records calls and returns deterministic results without signing
+// The zero value is ready to use. Generated keys keep the "<dir>/<prefix>_<file>" shape of the
+// default naming strategy with the call number as prefix, and URLs carry a fake signature.
+type FakeGenerator struct {
+	// BucketName is the default bucket (default: "test-bucket")
+	BucketName string
+	// Expiry is the default expiry (default: 15 minutes)
+	Expiry time.Duration
+	// Now is the time URLs are issued at (default: 2025-01-01T00:00:00Z)
+	Now time.Time
+	// Err is returned by every call when set
+	Err error
+	// ErrFunc returns the error for a call, e.g. to fail one method or object; Err is used when it returns nil
+	ErrFunc func(call Call) error
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+var _ gcsurl.URLSigner = (*FakeGenerator)(nil)
+
+// Calls returns the recorded calls in order
+func (f *FakeGenerator) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call(nil), f.calls...)
+}
+
+// CallsTo returns the recorded calls of one method
+func (f *FakeGenerator) CallsTo(method string) []Call {
+	var calls []Call
+	for _, call := range f.Calls() {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Reset forgets the recorded calls and restarts call numbering
+func (f *FakeGenerator) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = nil
+}
+
+// GetBucketName implements gcsurl.URLSigner
+func (f *FakeGenerator) GetBucketName() string {
+	if f.BucketName == "" {
+		return "test-bucket"
+	}
+	return f.BucketName
+}
+
+// GetDefaultExpiry implements gcsurl.URLSigner
+func (f *FakeGenerator) GetDefaultExpiry() time.Duration {
+	if f.Expiry <= 0 {
+		return 15 * time.Minute
+	}
+	return f.Expiry
+}
+
+// record saves a call and returns its 1-based number, or the configured error
+func (f *FakeGenerator) record(call Call) (int, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, call)
+	n := len(f.calls)
+	f.mu.Unlock()
+
+	if f.ErrFunc != nil {
+		if err := f.ErrFunc(call); err != nil {
+			return 0, err
+		}
+	}
+	return n, f.Err
+}
+
+// issuedAt returns the time URLs are issued at
+func (f *FakeGenerator) issuedAt() time.Time {
+	if f.Now.IsZero() {
+		return fakeEpoch
+	}
+	return f.Now
+}
+
+// fakeURL returns a deterministic URL for an object
+func (f *FakeGenerator) fakeURL(method, bucketName, objectName string, expiry time.Duration) string {
+	u := url.URL{Scheme: "https", Host: "storage.googleapis.com", Path: "/" + bucketName + "/" + objectName}
+	u.RawQuery = url.Values{
+		"X-Goog-Expires":   {strconv.Itoa(int(expiry.Seconds()))},
+		"X-Goog-Method":    {method},
+		"X-Goog-Signature": {"fake"},
+	}.Encode()
+	return u.String()
+}
+
+// upload records an upload call and returns its deterministic result
+func (f *FakeGenerator) upload(call Call, options gcsurl.UploadOptions) (gcsurl.DocumentUpload, error) {
+	n, err := f.record(call)
+	if err != nil {
+		return gcsurl.DocumentUpload{}, err
+	}
+
+	key := call.Object
+	if !options.UseOriginalName {
+		dir, file := path.Split(call.Object)
+		key = fmt.Sprintf("%s%08x_%s", dir, n, file)
+	}
+	expiry := f.GetDefaultExpiry()
+	if options.Expiry > 0 {
+		expiry = options.Expiry
+	}
+
+	// Same headers as a generator without restrictions or bucket defaults
+	headers, err := hooks.UploadHeaders(options)
+	if err != nil {
+		return gcsurl.DocumentUpload{}, err
+	}
+	var checksum *gcsurl.Checksum
+	if options.Checksum != (gcsurl.Checksum{}) {
+		checksum = &options.Checksum
+	}
+
+	return gcsurl.DocumentUpload{
+		UploadURL:    f.fakeURL("PUT", call.Bucket, key, expiry),
+		ExpiresAt:    f.issuedAt().Add(expiry),
+		GeneratedKey: key,
+		OriginalName: call.Object,
+		Headers:      headers,
+		Checksum:     checksum,
+	}, nil
+}
+
+// download records a download call and returns its deterministic result
+func (f *FakeGenerator) download(call Call, options gcsurl.DownloadOptions) (gcsurl.DocumentDownload, error) {
+	if _, err := f.record(call); err != nil {
+		return gcsurl.DocumentDownload{}, err
+	}
+
+	expiry := f.GetDefaultExpiry()
+	if options.Expiry > 0 {
+		expiry = options.Expiry
+	}
+	return gcsurl.DocumentDownload{
+		DownloadURL: f.fakeURL("GET", call.Bucket, call.Object, expiry),
+		ExpiresAt:   f.issuedAt().Add(expiry),
+		Method:      "GET",
+		Bucket:      call.Bucket,
+		Object:      call.Object,
+	}, nil
+}
+
+// GenerateSignedUploadURL implements gcsurl.UploadURLSigner
+func (f *FakeGenerator) GenerateSignedUploadURL(ctx context.Context, objectName string) (gcsurl.DocumentUpload, error) {
+	call := Call{Method: "GenerateSignedUploadURL", Bucket: f.GetBucketName(), Object: objectName}
+	return f.upload(call, gcsurl.UploadOptions{})
+}
+
+// GenerateSignedUploadURLWithBucket implements gcsurl.UploadURLSigner
+func (f *FakeGenerator) GenerateSignedUploadURLWithBucket(ctx context.Context, bucketName, objectName string) (gcsurl.DocumentUpload, error) {
+	call := Call{Method: "GenerateSignedUploadURLWithBucket", Bucket: bucketName, Object: objectName}
+	return f.upload(call, gcsurl.UploadOptions{})
+}
+
+// GenerateSignedUploadURLWithOptions implements gcsurl.UploadURLSigner
+func (f *FakeGenerator) GenerateSignedUploadURLWithOptions(ctx context.Context, bucketName, objectName string, options gcsurl.UploadOptions) (gcsurl.DocumentUpload, error) {
+	call := Call{Method: "GenerateSignedUploadURLWithOptions", Bucket: bucketName, Object: objectName, Options: options}
+	return f.upload(call, options)
+}
+
+// GenerateSignedUploadURLWithChecksum implements gcsurl.UploadURLSigner
+func (f *FakeGenerator) GenerateSignedUploadURLWithChecksum(ctx context.Context, objectName string, checksum gcsurl.Checksum) (gcsurl.DocumentUpload, error) {
+	call := Call{Method: "GenerateSignedUploadURLWithChecksum", Bucket: f.GetBucketName(), Object: objectName, Options: checksum}
+	return f.upload(call, gcsurl.UploadOptions{Checksum: checksum})
+}
+
+// GenerateSignedUploadURLWithExpiry implements gcsurl.UploadURLSigner
+func (f *FakeGenerator) GenerateSignedUploadURLWithExpiry(ctx context.Context, bucketName, objectName string, expiry time.Duration) (gcsurl.DocumentUpload, error) {
+	call := Call{Method: "GenerateSignedUploadURLWithExpiry", Bucket: bucketName, Object: objectName, Options: expiry}
+	return f.upload(call, gcsurl.UploadOptions{Expiry: expiry, UseOriginalName: true})
+}
+
+// GenerateSignedUploadURLWithOriginalName implements gcsurl.UploadURLSigner
+func (f *FakeGenerator) GenerateSignedUploadURLWithOriginalName(ctx context.Context, objectName string) (gcsurl.DocumentUpload, error) {
+	call := Call{Method: "GenerateSignedUploadURLWithOriginalName", Bucket: f.GetBucketName(), Object: objectName}
+	return f.upload(call, gcsurl.UploadOptions{UseOriginalName: true})
+}
+
+// GenerateSignedDownloadURL implements gcsurl.DownloadURLSigner
+func (f *FakeGenerator) GenerateSignedDownloadURL(ctx context.Context, objectName string) (string, error) {
+	download, err := f.download(Call{Method: "GenerateSignedDownloadURL", Bucket: f.GetBucketName(), Object: objectName}, gcsurl.DownloadOptions{})
+	return download.DownloadURL, err
+}
+
+// GenerateSignedDownloadURLWithBucket implements gcsurl.DownloadURLSigner
+func (f *FakeGenerator) GenerateSignedDownloadURLWithBucket(ctx context.Context, bucketName, objectName string) (string, error) {
+	download, err := f.download(Call{Method: "GenerateSignedDownloadURLWithBucket", Bucket: bucketName, Object: objectName}, gcsurl.DownloadOptions{})
+	return download.DownloadURL, err
+}
+
+// GenerateSignedDownloadURLWithExpiry implements gcsurl.DownloadURLSigner
+func (f *FakeGenerator) GenerateSignedDownloadURLWithExpiry(ctx context.Context, bucketName, objectName string, expiry time.Duration) (string, error) {
+	call := Call{Method: "GenerateSignedDownloadURLWithExpiry", Bucket: bucketName, Object: objectName, Options: expiry}
+	download, err := f.download(call, gcsurl.DownloadOptions{Expiry: expiry})
+	return download.DownloadURL, err
+}
+
+// GenerateSignedDownloadURLWithOptions implements gcsurl.DownloadURLSigner
+func (f *FakeGenerator) GenerateSignedDownloadURLWithOptions(ctx context.Context, bucketName, objectName string, options gcsurl.DownloadOptions) (string, error) {
+	call := Call{Method: "GenerateSignedDownloadURLWithOptions", Bucket: bucketName, Object: objectName, Options: options}
+	download, err := f.download(call, options)
+	return download.DownloadURL, err
+}
+
+// GenerateSignedDownload implements gcsurl.DownloadURLSigner
+func (f *FakeGenerator) GenerateSignedDownload(ctx context.Context, objectName string) (gcsurl.DocumentDownload, error) {
+	return f.download(Call{Method: "GenerateSignedDownload", Bucket: f.GetBucketName(), Object: objectName}, gcsurl.DownloadOptions{})
+}
+
+// GenerateSignedDownloadWithBucket implements gcsurl.DownloadURLSigner
+func (f *FakeGenerator) GenerateSignedDownloadWithBucket(ctx context.Context, bucketName, objectName string) (gcsurl.DocumentDownload, error) {
+	return f.download(Call{Method: "GenerateSignedDownloadWithBucket", Bucket: bucketName, Object: objectName}, gcsurl.DownloadOptions{})
+}
+
+// GenerateSignedDownloadWithOptions implements gcsurl.DownloadURLSigner
+func (f *FakeGenerator) GenerateSignedDownloadWithOptions(ctx context.Context, bucketName, objectName string, options gcsurl.DownloadOptions) (gcsurl.DocumentDownload, error) {
+	call := Call{Method: "GenerateSignedDownloadWithOptions", Bucket: bucketName, Object: objectName, Options: options}
+	return f.download(call, options)
+}
@@ -0,0 +1,58 @@
+package gcsurltest_test
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"testing"
+
+	"github.com/tropikoearth/gcsurl"
+	"github.com/tropikoearth/gcsurl/gcsurltest"
+)
+
+func TestFakeGeneratorHeadersMatchGenerator(t *testing.T) {
+	server, err := gcsurltest.NewServer(gcsurltest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	generator, err := gcsurl.NewURLGeneratorWithConfig(server.Config("documents"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := &gcsurltest.FakeGenerator{BucketName: "documents"}
+
+	tests := []struct {
+		name    string
+		options gcsurl.UploadOptions
+	}{
+		{"no options", gcsurl.UploadOptions{}},
+		{"metadata", gcsurl.UploadOptions{Metadata: map[string]string{"Owner": "u123", "x-goog-meta-tenant": "acme"}}},
+		{"checksum", gcsurl.UploadOptions{Checksum: gcsurl.Checksum{MD5: "1B2M2Y8AsgTpgAmY7PhCfg==", CRC32C: "AAAAAA=="}}},
+		{"precondition", gcsurl.UploadOptions{DoesNotExist: true}},
+		{"storage", gcsurl.UploadOptions{Storage: gcsurl.StorageOptions{StorageClass: "nearline", CacheControl: "no-store"}}},
+		{"KMS key", gcsurl.UploadOptions{EncryptionKey: &gcsurl.EncryptionKey{KMSKeyName: "projects/p/locations/l/keyRings/r/cryptoKeys/k"}}},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := generator.GenerateSignedUploadURLWithOptions(ctx, "documents", "reports/q1.bin", tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := fake.GenerateSignedUploadURLWithOptions(ctx, "documents", "reports/q1.bin", tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !maps.Equal(got.Headers, want.Headers) {
+				t.Errorf("fake headers = %v, want %v", got.Headers, want.Headers)
+			}
+		})
+	}
+
+	invalid := gcsurl.UploadOptions{Metadata: map[string]string{"bad key": "x"}}
+	if _, err := fake.GenerateSignedUploadURLWithOptions(ctx, "documents", "a.bin", invalid); !errors.Is(err, gcsurl.ErrInvalidInput) {
+		t.Errorf("invalid metadata: error = %v, want ErrInvalidInput", err)
+	}
+}
@@ -0,0 +1,244 @@
+package gcsurltest
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxFormBytes caps the size of a POST policy form upload
+const maxFormBytes = 64 << 20
+
+// unconditionedFields are form fields a policy does not need a condition for
+var unconditionedFields = map[string]bool{"policy": true, "x-goog-signature": true, "file": true}
+
+// postPolicy is the decoded policy form field
+type postPolicy struct {
+	Expiration string            `json:"expiration"`
+	Conditions []json.RawMessage `json:"conditions"`
+}
+
+// postPolicyForm is a parsed multipart form upload
+type postPolicyForm struct {
+	fields   map[string]string // Lowercase field names
+	filename string
+	data     []byte
+}
+
+// postPolicyUpload serves a browser form upload signed with a V4 POST policy
+func (s *Server) postPolicyUpload(w http.ResponseWriter, r *http.Request, bucketName string) *apiError {
+	form, apiErr := readPostPolicyForm(w, r)
+	if apiErr != nil {
+		return apiErr
+	}
+	if apiErr := s.checkPolicySignature(form.fields); apiErr != nil {
+		return apiErr
+	}
+
+	rawPolicy, err := base64.StdEncoding.DecodeString(form.fields["policy"])
+	var policy postPolicy
+	if err == nil {
+		err = json.Unmarshal(rawPolicy, &policy)
+	}
+	if err != nil {
+		return errorf(http.StatusBadRequest, "InvalidPolicyDocument", "policy is not base64 JSON: %v", err)
+	}
+	expiration, err := time.Parse(time.RFC3339, policy.Expiration)
+	if err != nil {
+		return errorf(http.StatusBadRequest, "InvalidPolicyDocument", "invalid expiration %q", policy.Expiration)
+	}
+	if !s.now().Before(expiration) {
+		return errorf(http.StatusForbidden, "AccessDenied", "Invalid according to Policy: Policy expired.")
+	}
+	if apiErr := checkPolicyConditions(policy.Conditions, form, bucketName); apiErr != nil {
+		return apiErr
+	}
+
+	header := make(http.Header)
+	for name, value := range form.fields {
+		header.Set(name, value)
+	}
+	object, apiErr := objectFromHeaders(header)
+	if apiErr != nil {
+		return apiErr
+	}
+	object.Name = strings.ReplaceAll(form.fields["key"], "${filename}", form.filename)
+	object.Data = form.data
+	if object.Name == "" {
+		return errorf(http.StatusBadRequest, "InvalidArgument", "the key field is required")
+	}
+
+	s.mu.Lock()
+	if apiErr := checkGenerationMatch(header, s.buckets[bucketName][object.Name]); apiErr != nil {
+		s.mu.Unlock()
+		return apiErr
+	}
+	stored := s.store(bucketName, object).clone()
+	s.mu.Unlock()
+
+	setObjectHeaders(w.Header(), &stored)
+	if redirect := form.fields["success_action_redirect"]; redirect != "" {
+		target, err := url.Parse(redirect)
+		if err != nil {
+			return errorf(http.StatusBadRequest, "InvalidArgument", "invalid success_action_redirect %q", redirect)
+		}
+		query := target.Query()
+		query.Set("bucket", bucketName)
+		query.Set("key", stored.Name)
+		query.Set("etag", w.Header().Get("ETag"))
+		target.RawQuery = query.Encode()
+		http.Redirect(w, r, target.String(), http.StatusSeeOther)
+		return nil
+	}
+
+	switch form.fields["success_action_status"] {
+	case "200":
+		w.WriteHeader(http.StatusOK)
+	case "201":
+		w.Header().Set("Content-Type", "application/xml; charset=UTF-8")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, xml.Header)
+		xml.NewEncoder(w).Encode(struct {
+			XMLName  xml.Name `xml:"PostResponse"`
+			Location string   `xml:"Location"`
+			Bucket   string   `xml:"Bucket"`
+			Key      string   `xml:"Key"`
+			ETag     string   `xml:"ETag"`
+		}{Location: s.URL + "/" + bucketName + "/" + stored.Name, Bucket: bucketName, Key: stored.Name, ETag: w.Header().Get("ETag")})
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+	return nil
+}
+
+// readPostPolicyForm reads the form fields up to the file field, which must come last
+func readPostPolicyForm(w http.ResponseWriter, r *http.Request) (*postPolicyForm, *apiError) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBytes)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, errorf(http.StatusBadRequest, "InvalidArgument", "POST policy uploads must be multipart/form-data: %v", err)
+	}
+
+	form := &postPolicyForm{fields: make(map[string]string)}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, errorf(http.StatusBadRequest, "InvalidArgument", "the form has no file field")
+		}
+		if err != nil {
+			return nil, errorf(http.StatusBadRequest, "InvalidArgument", "failed to read form: %v", err)
+		}
+		value, err := io.ReadAll(part)
+		if err != nil {
+			return nil, errorf(http.StatusBadRequest, "IncompleteBody", "failed to read form field %s: %v", part.FormName(), err)
+		}
+		name := strings.ToLower(part.FormName())
+		if name == "file" {
+			// Fields after the file are ignored, as GCS does
+			form.filename, form.data = part.FileName(), value
+			return form, nil
+		}
+		form.fields[name] = string(value)
+	}
+}
+
+// checkPolicySignature checks the x-goog-signature of the base64 policy
+func (s *Server) checkPolicySignature(fields map[string]string) *apiError {
+	if algorithm := fields["x-goog-algorithm"]; algorithm != "GOOG4-RSA-SHA256" {
+		return errorf(http.StatusBadRequest, "InvalidArgument", "unsupported x-goog-algorithm %q", algorithm)
+	}
+	accessID, _, _ := strings.Cut(fields["x-goog-credential"], "/")
+	s.mu.Lock()
+	key, ok := s.keys[accessID]
+	s.mu.Unlock()
+	if !ok {
+		return errorf(http.StatusForbidden, "AccessDenied", "%q is not trusted by this server", accessID)
+	}
+
+	signature, err := hex.DecodeString(fields["x-goog-signature"])
+	sum := sha256.Sum256([]byte(fields["policy"]))
+	if err != nil || rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], signature) != nil {
+		return &apiError{
+			Status:       http.StatusForbidden,
+			Code:         "SignatureDoesNotMatch",
+			Message:      "The request signature we calculated does not match the signature you provided.",
+			StringToSign: fields["policy"],
+		}
+	}
+	return nil
+}
+
+// checkPolicyConditions checks the form against every policy condition
+// Every field except the policy, signature and file must be covered by a condition.
+func checkPolicyConditions(conditions []json.RawMessage, form *postPolicyForm, bucketName string) *apiError {
+	covered := make(map[string]bool)
+	fieldValue := func(name string) string {
+		name = strings.ToLower(strings.TrimPrefix(name, "$"))
+		covered[name] = true
+		if name == "bucket" {
+			return bucketName
+		}
+		return form.fields[name]
+	}
+	failed := func(condition json.RawMessage) *apiError {
+		return errorf(http.StatusForbidden, "AccessDenied", "Invalid according to Policy: Policy Condition failed: %s", condition)
+	}
+
+	for _, condition := range conditions {
+		var exact map[string]string
+		if json.Unmarshal(condition, &exact) == nil {
+			for name, value := range exact {
+				if fieldValue(name) != value {
+					return failed(condition)
+				}
+			}
+			continue
+		}
+
+		var operation []any
+		if json.Unmarshal(condition, &operation) != nil || len(operation) != 3 {
+			return errorf(http.StatusBadRequest, "InvalidPolicyDocument", "invalid condition %s", condition)
+		}
+		operator, _ := operation[0].(string)
+		switch operator {
+		case "eq", "starts-with":
+			name, _ := operation[1].(string)
+			expected, _ := operation[2].(string)
+			actual := fieldValue(name)
+			if operator == "eq" && actual != expected || operator == "starts-with" && !strings.HasPrefix(actual, expected) {
+				return failed(condition)
+			}
+		case "content-length-range":
+			minSize, _ := operation[1].(float64)
+			maxSize, _ := operation[2].(float64)
+			if apiErr := checkContentLengthRange(fmt.Sprintf("%d,%d", int64(minSize), int64(maxSize)), int64(len(form.data))); apiErr != nil {
+				return apiErr
+			}
+		default:
+			return errorf(http.StatusBadRequest, "InvalidPolicyDocument", "unknown condition operator %q", operator)
+		}
+	}
+
+	for name := range form.fields {
+		if !covered[name] && !unconditionedFields[name] && !strings.HasPrefix(name, "x-ignore-") {
+			return errorf(http.StatusForbidden, "AccessDenied", "Invalid according to Policy: Extra input fields: %s", name)
+		}
+	}
+	if status := form.fields["success_action_status"]; status != "" {
+		if code, err := strconv.Atoi(status); err != nil || code != 200 && code != 201 && code != 204 {
+			return errorf(http.StatusBadRequest, "InvalidArgument", "success_action_status must be 200, 201 or 204")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,580 @@
+// Package gcsurltest provides an in-process fake of the Cloud Storage XML API for integration tests
+//
+// The fake only accepts requests that carry a valid V4 signature, so URLs signed by a
+// gcsurl.URLGenerator can be exercised end to end without real credentials or buckets:
+//
+//	server, err := gcsurltest.NewServer(gcsurltest.Options{})
+//	defer server.Close()
+//	generator, err := gcsurl.NewURLGeneratorWithConfig(server.Config("my-bucket"))
+//
+// PUT, GET, HEAD and DELETE on signed URLs and POST policy form uploads are emulated, including
+// signed headers, expiry, x-goog-content-length-range, checksums and generation preconditions.
+// Objects are kept in memory. Resumable and multipart uploads are answered with 501.
+//
+// For unit tests that do not need HTTP at all, FakeGenerator implements gcsurl.URLSigner with
+// recorded calls, deterministic results and configurable errors.
+package gcsurltest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/tropikoearth/gcsurl"
+)
+
+// KeySigner is a signer whose public key is known, such as *gcsurl.TestSigner and *gcsurl.PEMSigner
+type KeySigner interface {
+	gcsurl.Signer
+	PublicKey() *rsa.PublicKey
+}
+
+// Options configures a Server
+type Options struct {
+	// Signer signs the URLs the server accepts (default: a new gcsurl.TestSigner)
+	Signer KeySigner
+	// Now is the clock signatures and policies are checked against (default: time.Now)
+	Now func() time.Time
+}
+
+// Object is a stored object
+type Object struct {
+	Bucket             string
+	Name               string
+	Data               []byte
+	ContentType        string
+	CacheControl       string
+	ContentDisposition string
+	ContentEncoding    string
+	ContentLanguage    string
+	Metadata           map[string]string // Custom metadata without the x-goog-meta- prefix
+	StorageClass       string
+	ACL                string // Predefined ACL from x-goog-acl, if any
+	Checksum           gcsurl.Checksum
+	Generation         int64
+	Updated            time.Time
+	CustomerKeySHA256  string // Base64 SHA-256 of the CSEK the object is encrypted with
+	KMSKeyName         string // CMEK the object is encrypted with
+}
+
+// Server is a fake Cloud Storage XML API that only accepts requests signed for it
+type Server struct {
+	// URL is the base URL of the server, e.g. "http://127.0.0.1:54321"; use it as Config.Endpoint
+	URL string
+
+	server *httptest.Server
+	signer KeySigner
+	now    func() time.Time
+
+	mu             sync.Mutex
+	keys           map[string]*rsa.PublicKey
+	buckets        map[string]map[string]*Object
+	lastGeneration int64
+}
+
+// NewServer starts a Server; call Close when done
+func NewServer(options Options) (*Server, error) {
+	if options.Signer == nil {
+		signer, err := gcsurl.NewTestSigner("")
+		if err != nil {
+			return nil, err
+		}
+		options.Signer = signer
+	}
+	if options.Now == nil {
+		options.Now = time.Now
+	}
+
+	s := &Server{
+		signer:  options.Signer,
+		now:     options.Now,
+		keys:    make(map[string]*rsa.PublicKey),
+		buckets: make(map[string]map[string]*Object),
+	}
+	accessID, err := s.signer.AccessID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get signer access ID: %w", err)
+	}
+	s.Trust(accessID, s.signer.PublicKey())
+
+	s.server = httptest.NewServer(s)
+	s.URL = s.server.URL
+	return s, nil
+}
+
+// Close shuts the server down
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Signer returns the signer whose URLs the server accepts
+func (s *Server) Signer() KeySigner {
+	return s.signer
+}
+
+// Config returns a generator config that signs URLs for the server and verifies uploads against it
+func (s *Server) Config(bucketName string) gcsurl.Config {
+	return gcsurl.Config{
+		BucketName:  bucketName,
+		Signer:      s.signer,
+		Endpoint:    s.URL,
+		ObjectStore: s,
+	}
+}
+
+// Trust accepts signatures by another service account, e.g. a generator with its own signer
+func (s *Server) Trust(accessID string, key *rsa.PublicKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[accessID] = key
+}
+
+// PutObject stores an object directly, e.g. to seed a test, and returns it
+func (s *Server) PutObject(bucketName, objectName string, data []byte, contentType string) Object {
+	object := &Object{Name: objectName, Data: bytes.Clone(data), ContentType: contentType}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.store(bucketName, object).clone()
+}
+
+// Object returns a stored object
+func (s *Server) Object(bucketName, objectName string) (Object, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	object, ok := s.buckets[bucketName][objectName]
+	if !ok {
+		return Object{}, false
+	}
+	return object.clone(), true
+}
+
+// Objects returns the sorted names of the objects in a bucket
+func (s *Server) Objects(bucketName string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Sorted(maps.Keys(s.buckets[bucketName]))
+}
+
+// ReadObjectPrefix implements gcsurl.ObjectStore
+func (s *Server) ReadObjectPrefix(ctx context.Context, bucketName, objectName string, n int64) ([]byte, error) {
+	object, ok := s.Object(bucketName, objectName)
+	if !ok {
+		return nil, storage.ErrObjectNotExist
+	}
+	return object.Data[:min(n, int64(len(object.Data)))], nil
+}
+
+// CopyObject implements gcsurl.ObjectStore
+func (s *Server) CopyObject(ctx context.Context, srcBucket, srcObject, dstBucket, dstObject string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	source, ok := s.buckets[srcBucket][srcObject]
+	if !ok {
+		return storage.ErrObjectNotExist
+	}
+	copied := source.clone()
+	copied.Name = dstObject
+	s.store(dstBucket, &copied)
+	return nil
+}
+
+// DeleteObject implements gcsurl.ObjectStore
+func (s *Server) DeleteObject(ctx context.Context, bucketName, objectName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.buckets[bucketName][objectName]; !ok {
+		return storage.ErrObjectNotExist
+	}
+	delete(s.buckets[bucketName], objectName)
+	return nil
+}
+
+// ObjectChecksum implements gcsurl.ChecksumReader
+func (s *Server) ObjectChecksum(ctx context.Context, bucketName, objectName string) (gcsurl.Checksum, error) {
+	object, ok := s.Object(bucketName, objectName)
+	if !ok {
+		return gcsurl.Checksum{}, storage.ErrObjectNotExist
+	}
+	return object.Checksum, nil
+}
+
+// store saves an object as the next generation; s.mu must be held
+func (s *Server) store(bucketName string, object *Object) *Object {
+	object.Bucket = bucketName
+	object.Updated = s.now().UTC()
+	// Like GCS, generations are microsecond timestamps, kept strictly increasing
+	s.lastGeneration = max(s.lastGeneration+1, object.Updated.UnixMicro())
+	object.Generation = s.lastGeneration
+	if object.ContentType == "" {
+		object.ContentType = "application/octet-stream"
+	}
+	if object.StorageClass == "" {
+		object.StorageClass = "STANDARD"
+	}
+	object.Checksum, _ = gcsurl.ComputeChecksum(bytes.NewReader(object.Data))
+
+	if s.buckets[bucketName] == nil {
+		s.buckets[bucketName] = make(map[string]*Object)
+	}
+	s.buckets[bucketName][object.Name] = object
+	return object
+}
+
+// clone returns a deep copy of the object
+func (o *Object) clone() Object {
+	c := *o
+	c.Data = bytes.Clone(o.Data)
+	c.Metadata = maps.Clone(o.Metadata)
+	return c
+}
+
+// apiError is an XML API error answer
+type apiError struct {
+	XMLName          xml.Name `xml:"Error"`
+	Status           int      `xml:"-"`
+	Code             string   `xml:"Code"`
+	Message          string   `xml:"Message"`
+	Details          string   `xml:"Details,omitempty"`
+	StringToSign     string   `xml:"StringToSign,omitempty"`
+	CanonicalRequest string   `xml:"CanonicalRequest,omitempty"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// errorf returns an API error with a formatted message
+func errorf(status int, code, format string, args ...any) *apiError {
+	return &apiError{Status: status, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// writeError answers with an XML error body
+func writeError(w http.ResponseWriter, err *apiError) {
+	w.Header().Set("Content-Type", "application/xml; charset=UTF-8")
+	w.WriteHeader(err.Status)
+	io.WriteString(w, xml.Header)
+	xml.NewEncoder(w).Encode(err)
+}
+
+// ServeHTTP serves path-style XML API requests: /<bucket>/<object>
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucketName, objectName, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucketName == "" {
+		writeError(w, errorf(http.StatusBadRequest, "InvalidURI", "bucket name is required"))
+		return
+	}
+
+	var err *apiError
+	switch {
+	case r.Method == http.MethodPost && objectName == "" && !r.URL.Query().Has("X-Goog-Signature"):
+		err = s.postPolicyUpload(w, r, bucketName)
+	case objectName == "":
+		err = errorf(http.StatusNotImplemented, "NotImplemented", "bucket requests are not emulated")
+	default:
+		if err = s.authorize(r); err == nil {
+			err = s.serveObject(w, r, bucketName, objectName)
+		}
+	}
+	if err != nil {
+		writeError(w, err)
+	}
+}
+
+// authorize checks the V4 signature, signed headers and expiry of a signed URL request
+func (s *Server) authorize(r *http.Request) *apiError {
+	if !r.URL.Query().Has("X-Goog-Signature") {
+		return errorf(http.StatusForbidden, "AccessDenied", "anonymous callers do not have access; sign the URL")
+	}
+	signed, err := gcsurl.ParseSignedURL("http://" + r.Host + r.URL.RequestURI())
+	if err != nil {
+		return errorf(http.StatusBadRequest, "AuthorizationQueryParametersError", "%v", err)
+	}
+
+	s.mu.Lock()
+	key, ok := s.keys[signed.AccessID]
+	s.mu.Unlock()
+	if !ok {
+		return errorf(http.StatusForbidden, "AccessDenied", "%s is not trusted by this server", signed.AccessID)
+	}
+
+	err = signed.Verify(gcsurl.SignatureVerifyOptions{Method: r.Method, Headers: r.Header, PublicKey: key, Now: s.now()})
+	var signatureErr *gcsurl.SignatureError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &signatureErr) && signatureErr.Component == gcsurl.ComponentExpiry:
+		return errorf(http.StatusBadRequest, "ExpiredToken", "%s", signatureErr.Reason)
+	case errors.As(err, &signatureErr):
+		return &apiError{
+			Status:           http.StatusForbidden,
+			Code:             "SignatureDoesNotMatch",
+			Message:          "The request signature we calculated does not match the signature you provided.",
+			Details:          fmt.Sprintf("%s: %s", signatureErr.Component, signatureErr.Reason),
+			StringToSign:     signatureErr.StringToSign,
+			CanonicalRequest: signatureErr.CanonicalRequest,
+		}
+	}
+	return errorf(http.StatusBadRequest, "InvalidArgument", "%v", err)
+}
+
+// serveObject serves an authorized object request
+func (s *Server) serveObject(w http.ResponseWriter, r *http.Request, bucketName, objectName string) *apiError {
+	query := r.URL.Query()
+	if query.Has("uploads") || query.Has("uploadId") || r.Header.Get("x-goog-resumable") != "" {
+		return errorf(http.StatusNotImplemented, "NotImplemented", "resumable and multipart uploads are not emulated")
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		return s.putObject(w, r, bucketName, objectName)
+	case http.MethodGet, http.MethodHead:
+		return s.getObject(w, r, bucketName, objectName)
+	case http.MethodDelete:
+		return s.deleteObject(w, r, bucketName, objectName)
+	}
+	return errorf(http.StatusNotImplemented, "NotImplemented", "%s requests on objects are not emulated", r.Method)
+}
+
+// putObject stores the request body, or copies x-goog-copy-source
+func (s *Server) putObject(w http.ResponseWriter, r *http.Request, bucketName, objectName string) *apiError {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return errorf(http.StatusBadRequest, "IncompleteBody", "failed to read body: %v", err)
+	}
+	if apiErr := checkContentLengthRange(r.Header.Get("x-goog-content-length-range"), int64(len(data))); apiErr != nil {
+		return apiErr
+	}
+	if apiErr := checkDigests(r.Header, data); apiErr != nil {
+		return apiErr
+	}
+
+	object, apiErr := objectFromHeaders(r.Header)
+	if apiErr != nil {
+		return apiErr
+	}
+	object.Name = objectName
+	object.Data = data
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if source := r.Header.Get("x-goog-copy-source"); source != "" {
+		if apiErr := s.copySource(object, source, r.Header); apiErr != nil {
+			return apiErr
+		}
+		object.Name = objectName
+	}
+	if apiErr := checkGenerationMatch(r.Header, s.buckets[bucketName][objectName]); apiErr != nil {
+		return apiErr
+	}
+
+	stored := s.store(bucketName, object)
+	setObjectHeaders(w.Header(), stored)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// copySource fills object with the content of a copy source; s.mu must be held
+// Metadata is copied as well unless x-goog-metadata-directive is REPLACE.
+func (s *Server) copySource(object *Object, source string, header http.Header) *apiError {
+	sourceBucket, sourceName, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	sourceName, err := url.PathUnescape(sourceName)
+	if err != nil {
+		return errorf(http.StatusBadRequest, "InvalidArgument", "copy source %s is not URL-encoded: %v", source, err)
+	}
+	current, ok := s.buckets[sourceBucket][sourceName]
+	if !ok {
+		return errorf(http.StatusNotFound, "NoSuchKey", "copy source %s does not exist", source)
+	}
+	if match := header.Get("x-goog-copy-source-if-generation-match"); match != "" && match != strconv.FormatInt(current.Generation, 10) {
+		return errorf(http.StatusPreconditionFailed, "PreconditionFailed", "copy source generation is %d, not %s", current.Generation, match)
+	}
+	if current.CustomerKeySHA256 != "" && header.Get("x-goog-copy-source-encryption-key-sha256") != current.CustomerKeySHA256 {
+		return errorf(http.StatusBadRequest, "ResourceIsEncryptedWithCustomerEncryptionKey", "copy source is encrypted with a customer-supplied key")
+	}
+
+	data := bytes.Clone(current.Data)
+	if !strings.EqualFold(header.Get("x-goog-metadata-directive"), "REPLACE") {
+		*object = current.clone()
+	}
+	object.Data = data
+	return nil
+}
+
+// getObject serves an object's content and headers, with Range support
+func (s *Server) getObject(w http.ResponseWriter, r *http.Request, bucketName, objectName string) *apiError {
+	object, ok := s.Object(bucketName, objectName)
+	if !ok {
+		return errorf(http.StatusNotFound, "NoSuchKey", "the specified key does not exist")
+	}
+	if object.CustomerKeySHA256 != "" && r.Header.Get("x-goog-encryption-key-sha256") != object.CustomerKeySHA256 {
+		return errorf(http.StatusBadRequest, "ResourceIsEncryptedWithCustomerEncryptionKey", "the object is encrypted with a customer-supplied key; send the same key")
+	}
+
+	setObjectHeaders(w.Header(), &object)
+	query := r.URL.Query()
+	if contentType := query.Get("response-content-type"); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	if disposition := query.Get("response-content-disposition"); disposition != "" {
+		w.Header().Set("Content-Disposition", disposition)
+	}
+	http.ServeContent(w, r, "", object.Updated, bytes.NewReader(object.Data))
+	return nil
+}
+
+// deleteObject deletes an object
+func (s *Server) deleteObject(w http.ResponseWriter, r *http.Request, bucketName, objectName string) *apiError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.buckets[bucketName][objectName]
+	if !ok {
+		return errorf(http.StatusNotFound, "NoSuchKey", "the specified key does not exist")
+	}
+	if apiErr := checkGenerationMatch(r.Header, current); apiErr != nil {
+		return apiErr
+	}
+	delete(s.buckets[bucketName], objectName)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// objectFromHeaders reads the attributes of a new object from request headers or form fields
+func objectFromHeaders(header http.Header) (*Object, *apiError) {
+	object := &Object{
+		ContentType:        header.Get("Content-Type"),
+		CacheControl:       header.Get("Cache-Control"),
+		ContentDisposition: header.Get("Content-Disposition"),
+		ContentEncoding:    header.Get("Content-Encoding"),
+		ContentLanguage:    header.Get("Content-Language"),
+		StorageClass:       header.Get("x-goog-storage-class"),
+		ACL:                header.Get("x-goog-acl"),
+		KMSKeyName:         header.Get("x-goog-encryption-kms-key-name"),
+	}
+	for name, values := range header {
+		if metaKey, ok := strings.CutPrefix(strings.ToLower(name), "x-goog-meta-"); ok && len(values) > 0 {
+			if object.Metadata == nil {
+				object.Metadata = make(map[string]string)
+			}
+			object.Metadata[metaKey] = values[0]
+		}
+	}
+
+	if key := header.Get("x-goog-encryption-key"); key != "" {
+		raw, err := base64.StdEncoding.DecodeString(key)
+		if err != nil || len(raw) != 32 || header.Get("x-goog-encryption-algorithm") != "AES256" {
+			return nil, errorf(http.StatusBadRequest, "InvalidArgument", "customer-supplied keys must be base64 AES256 keys of 32 bytes")
+		}
+		sum := sha256.Sum256(raw)
+		object.CustomerKeySHA256 = base64.StdEncoding.EncodeToString(sum[:])
+		if header.Get("x-goog-encryption-key-sha256") != object.CustomerKeySHA256 {
+			return nil, errorf(http.StatusBadRequest, "InvalidArgument", "x-goog-encryption-key-sha256 does not match the key")
+		}
+	}
+	return object, nil
+}
+
+// setObjectHeaders sets the response headers describing an object
+func setObjectHeaders(header http.Header, object *Object) {
+	header.Set("Content-Type", object.ContentType)
+	for name, value := range map[string]string{
+		"Cache-Control":       object.CacheControl,
+		"Content-Disposition": object.ContentDisposition,
+		"Content-Encoding":    object.ContentEncoding,
+		"Content-Language":    object.ContentLanguage,
+	} {
+		if value != "" {
+			header.Set(name, value)
+		}
+	}
+	for key, value := range object.Metadata {
+		header.Set("x-goog-meta-"+key, value)
+	}
+	md5, _ := base64.StdEncoding.DecodeString(object.Checksum.MD5)
+	header.Set("ETag", `"`+hex.EncodeToString(md5)+`"`)
+	header.Set("x-goog-generation", strconv.FormatInt(object.Generation, 10))
+	header.Set("x-goog-metageneration", "1")
+	header.Set("x-goog-hash", "crc32c="+object.Checksum.CRC32C)
+	header.Add("x-goog-hash", "md5="+object.Checksum.MD5)
+	header.Set("x-goog-storage-class", object.StorageClass)
+	if object.KMSKeyName != "" {
+		header.Set("x-goog-encryption-kms-key-name", object.KMSKeyName)
+	}
+	header.Set("x-goog-stored-content-length", strconv.Itoa(len(object.Data)))
+}
+
+// checkContentLengthRange enforces an x-goog-content-length-range of "min,max"
+func checkContentLengthRange(lengthRange string, size int64) *apiError {
+	if lengthRange == "" {
+		return nil
+	}
+	minText, maxText, _ := strings.Cut(lengthRange, ",")
+	minSize, minErr := strconv.ParseInt(strings.TrimSpace(minText), 10, 64)
+	maxSize, maxErr := strconv.ParseInt(strings.TrimSpace(maxText), 10, 64)
+	switch {
+	case minErr != nil || maxErr != nil:
+		return errorf(http.StatusBadRequest, "InvalidArgument", "invalid content length range %q", lengthRange)
+	case size < minSize:
+		return errorf(http.StatusBadRequest, "EntityTooSmall", "your proposed upload of %d bytes is smaller than the minimum of %d", size, minSize)
+	case size > maxSize:
+		return errorf(http.StatusBadRequest, "EntityTooLarge", "your proposed upload of %d bytes exceeds the maximum of %d", size, maxSize)
+	}
+	return nil
+}
+
+// checkDigests compares Content-MD5 and x-goog-hash with the body
+func checkDigests(header http.Header, data []byte) *apiError {
+	actual, _ := gcsurl.ComputeChecksum(bytes.NewReader(data))
+	if md5 := header.Get("Content-MD5"); md5 != "" && md5 != actual.MD5 {
+		return errorf(http.StatusBadRequest, "BadDigest", "the MD5 you specified did not match what we received")
+	}
+	for _, value := range header.Values("x-goog-hash") {
+		for _, hash := range strings.Split(value, ",") {
+			name, digest, _ := strings.Cut(strings.TrimSpace(hash), "=")
+			switch {
+			case name == "md5" && digest != actual.MD5, name == "crc32c" && digest != actual.CRC32C:
+				return errorf(http.StatusBadRequest, "BadDigest", "the %s you specified did not match what we received", name)
+			}
+		}
+	}
+	return nil
+}
+
+// checkGenerationMatch enforces x-goog-if-generation-match against the current object
+// Generation 0 requires that the object does not exist.
+func checkGenerationMatch(header http.Header, current *Object) *apiError {
+	match := header.Get("x-goog-if-generation-match")
+	if match == "" {
+		return nil
+	}
+	generation, err := strconv.ParseInt(match, 10, 64)
+	if err != nil {
+		return errorf(http.StatusBadRequest, "InvalidArgument", "invalid x-goog-if-generation-match %q", match)
+	}
+	switch {
+	case generation == 0 && current != nil:
+		return errorf(http.StatusPreconditionFailed, "PreconditionFailed", "the object already exists")
+	case generation != 0 && current == nil:
+		return errorf(http.StatusPreconditionFailed, "PreconditionFailed", "the object does not exist")
+	case generation != 0 && current.Generation != generation:
+		return errorf(http.StatusPreconditionFailed, "PreconditionFailed", "the object is at generation %d, not %d", current.Generation, generation)
+	}
+	return nil
+}
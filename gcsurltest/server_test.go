@@ -0,0 +1,271 @@
+package gcsurltest_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tropikoearth/gcsurl"
+	"github.com/tropikoearth/gcsurl/gcsurltest"
+)
+
+// send sends a request with headers to a signed URL and returns the status and body
+func send(t *testing.T, method, signedURL string, headers map[string]string, body []byte) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, signedURL, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(data)
+}
+
+// newGenerator returns a generator signing for the server
+func newGenerator(t *testing.T, server *gcsurltest.Server, restrictions *gcsurl.UploadRestrictions) *gcsurl.URLGenerator {
+	t.Helper()
+	config := server.Config("documents")
+	config.UploadRestrictions = restrictions
+	generator, err := gcsurl.NewURLGeneratorWithConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return generator
+}
+
+func TestServerUploadDownloadDelete(t *testing.T) {
+	server, err := gcsurltest.NewServer(gcsurltest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	generator := newGenerator(t, server, &gcsurl.UploadRestrictions{AllowedExtensions: []string{".pdf"}, MaxFileSizeBytes: 64, AllowMultiple: true})
+	ctx := context.Background()
+
+	data := []byte("%PDF-1.7 quarterly report")
+	checksum, err := gcsurl.ComputeChecksum(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	upload, err := generator.GenerateSignedUploadURLWithOptions(ctx, "documents", "reports/q1.pdf", gcsurl.UploadOptions{
+		Metadata: map[string]string{"owner": "u123"},
+		Checksum: checksum,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, body := send(t, http.MethodPut, upload.UploadURL, upload.Headers, data); status != http.StatusOK {
+		t.Fatalf("PUT: status %d: %s", status, body)
+	}
+	stored, ok := server.Object("documents", upload.GeneratedKey)
+	if !ok || stored.ContentType != "application/pdf" || stored.Metadata["owner"] != "u123" || stored.Checksum != checksum {
+		t.Errorf("stored object = %+v", stored)
+	}
+
+	download, err := generator.GenerateSignedDownloadWithOptions(ctx, "documents", upload.GeneratedKey, gcsurl.DownloadOptions{Disposition: gcsurl.DispositionAttachment})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, body := send(t, http.MethodGet, download.DownloadURL, download.Headers, nil); status != http.StatusOK || body != string(data) {
+		t.Errorf("GET: status %d, body %q", status, body)
+	}
+	headURL, err := generator.GenerateSignedHeadURL(ctx, upload.GeneratedKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := send(t, http.MethodHead, headURL, nil, nil); status != http.StatusOK {
+		t.Errorf("HEAD: status %d, want 200", status)
+	}
+
+	deleteURL, err := generator.GenerateSignedDeleteURL(ctx, upload.GeneratedKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, body := send(t, http.MethodDelete, deleteURL, nil, nil); status != http.StatusNoContent {
+		t.Fatalf("DELETE: status %d: %s", status, body)
+	}
+	if status, _ := send(t, http.MethodGet, download.DownloadURL, nil, nil); status != http.StatusNotFound {
+		t.Errorf("GET after DELETE: status %d, want 404", status)
+	}
+}
+
+func TestServerRejectsInvalidRequests(t *testing.T) {
+	var mu sync.Mutex
+	now := time.Now()
+	server, err := gcsurltest.NewServer(gcsurltest.Options{Now: func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	generator := newGenerator(t, server, &gcsurl.UploadRestrictions{AllowedExtensions: []string{".pdf"}, MaxFileSizeBytes: 16, AllowMultiple: true})
+
+	upload, err := generator.GenerateSignedUploadURLWithChecksum(context.Background(), "reports/q1.pdf", gcsurl.Checksum{MD5: "1B2M2Y8AsgTpgAmY7PhCfg=="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := make(map[string]string)
+	for name, value := range upload.Headers {
+		tampered[name] = value
+	}
+	tampered["Content-Type"] = "text/html"
+
+	tests := []struct {
+		name    string
+		url     string
+		headers map[string]string
+		body    string
+		status  int
+		code    string
+	}{
+		{"unsigned", strings.Split(upload.UploadURL, "?")[0], upload.Headers, "", http.StatusForbidden, "AccessDenied"},
+		{"tampered header", upload.UploadURL, tampered, "", http.StatusForbidden, "SignatureDoesNotMatch"},
+		{"too large", upload.UploadURL, upload.Headers, strings.Repeat("a", 17), http.StatusBadRequest, "EntityTooLarge"},
+		{"wrong checksum", upload.UploadURL, upload.Headers, "not empty", http.StatusBadRequest, "BadDigest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := send(t, http.MethodPut, tt.url, tt.headers, []byte(tt.body))
+			if status != tt.status || !strings.Contains(body, "<Code>"+tt.code+"</Code>") {
+				t.Errorf("status %d: %s, want %d %s", status, body, tt.status, tt.code)
+			}
+		})
+	}
+
+	mu.Lock()
+	now = now.Add(time.Hour)
+	mu.Unlock()
+	if status, body := send(t, http.MethodPut, upload.UploadURL, upload.Headers, nil); status != http.StatusBadRequest || !strings.Contains(body, "ExpiredToken") {
+		t.Errorf("expired URL: status %d: %s, want 400 ExpiredToken", status, body)
+	}
+}
+
+func TestServerContentLengthRange(t *testing.T) {
+	server, err := gcsurltest.NewServer(gcsurltest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	generator := newGenerator(t, server, &gcsurl.UploadRestrictions{MinFileSizeBytes: 4, MaxFileSizeBytes: 8, AllowMultiple: true})
+	ctx := context.Background()
+
+	upload, err := generator.GenerateSignedUploadURL(ctx, "reports/q1.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upload.Headers["x-goog-content-length-range"] != "4,8" {
+		t.Fatalf("Headers = %v, want x-goog-content-length-range 4,8", upload.Headers)
+	}
+	policy, err := generator.GenerateSignedPostPolicy(ctx, "reports/q2.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		size   int
+		status int
+		code   string
+	}{
+		{"too small", 3, http.StatusBadRequest, "EntityTooSmall"},
+		{"minimum", 4, http.StatusOK, ""},
+		{"maximum", 8, http.StatusOK, ""},
+		{"too large", 9, http.StatusBadRequest, "EntityTooLarge"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := strings.Repeat("a", tt.size)
+			status, body := send(t, http.MethodPut, upload.UploadURL, upload.Headers, []byte(data))
+			if status != tt.status || tt.code != "" && !strings.Contains(body, "<Code>"+tt.code+"</Code>") {
+				t.Errorf("PUT: status %d: %s, want %d %s", status, body, tt.status, tt.code)
+			}
+
+			// POST policies enforce the same range as a policy condition
+			var form bytes.Buffer
+			writer := multipart.NewWriter(&form)
+			for name, value := range policy.Fields {
+				writer.WriteField(name, value)
+			}
+			file, _ := writer.CreateFormFile("file", "q2.pdf")
+			file.Write([]byte(data))
+			writer.Close()
+			status, body = send(t, http.MethodPost, policy.URL, map[string]string{"Content-Type": writer.FormDataContentType()}, form.Bytes())
+			wantStatus := tt.status
+			if wantStatus == http.StatusOK {
+				wantStatus = http.StatusNoContent
+			}
+			if status != wantStatus || tt.code != "" && !strings.Contains(body, "<Code>"+tt.code+"</Code>") {
+				t.Errorf("POST: status %d: %s, want %d %s", status, body, wantStatus, tt.code)
+			}
+		})
+	}
+
+	// Multipart uploads check the declared size up front
+	for _, size := range []int64{3, 9} {
+		if _, err := generator.CreateMultipartUpload(ctx, "videos/clip.pdf", size); !errors.Is(err, gcsurl.ErrInvalidInput) {
+			t.Errorf("multipart upload of %d bytes: error = %v, want ErrInvalidInput", size, err)
+		}
+	}
+}
+
+func TestServerPreconditions(t *testing.T) {
+	server, err := gcsurltest.NewServer(gcsurltest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	generator := newGenerator(t, server, nil)
+	ctx := context.Background()
+
+	sign := func(options gcsurl.UploadOptions) gcsurl.DocumentUpload {
+		t.Helper()
+		options.UseOriginalName = true
+		upload, err := generator.GenerateSignedUploadURLWithOptions(ctx, "documents", "reports/q1.pdf", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return upload
+	}
+
+	create := sign(gcsurl.UploadOptions{DoesNotExist: true})
+	if status, body := send(t, http.MethodPut, create.UploadURL, create.Headers, []byte("v1")); status != http.StatusOK {
+		t.Fatalf("create: status %d: %s", status, body)
+	}
+	if status, _ := send(t, http.MethodPut, create.UploadURL, create.Headers, []byte("v1 again")); status != http.StatusPreconditionFailed {
+		t.Errorf("create when the object exists: status %d, want 412", status)
+	}
+
+	first, _ := server.Object("documents", "reports/q1.pdf")
+	replace := sign(gcsurl.UploadOptions{IfGenerationMatch: first.Generation})
+	if status, body := send(t, http.MethodPut, replace.UploadURL, replace.Headers, []byte("v2")); status != http.StatusOK {
+		t.Fatalf("replace current generation: status %d: %s", status, body)
+	}
+	if status, _ := send(t, http.MethodPut, replace.UploadURL, replace.Headers, []byte("v3")); status != http.StatusPreconditionFailed {
+		t.Errorf("replace stale generation: status %d, want 412", status)
+	}
+
+	second, _ := server.Object("documents", "reports/q1.pdf")
+	if string(second.Data) != "v2" || second.Generation <= first.Generation {
+		t.Errorf("stored %q at generation %d, want v2 after generation %d", second.Data, second.Generation, first.Generation)
+	}
+}
@@ -0,0 +1,352 @@
+// Package httphandler serves JSON endpoints that issue gcsurl signed upload and download URLs
+//
+// Mount a Handler under a prefix and clients POST JSON to it:
+//
+//	POST /upload    UploadRequest   -> gcsurl.DocumentUpload
+//	POST /download  DownloadRequest -> gcsurl.DocumentDownload
+//	POST /batch     BatchRequest    -> BatchResponse
+//
+// Invalid requests are answered with 4xx codes and an ErrorResponse body.
+package httphandler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/tropikoearth/gcsurl"
+)
+
+// defaultMaxBatchSize is the default number of items accepted in one batch request
+const defaultMaxBatchSize = 100
+
+// defaultMaxBodyBytes is the default size limit of a request body
+const defaultMaxBodyBytes = 1 << 20
+
+// ErrUnauthenticated can be returned by an AuthorizeFunc to answer 401 instead of 403
+var ErrUnauthenticated = errors.New("authentication required")
+
+// AuthorizeFunc decides whether the request may get a signed URL for an object
+// For uploads objectName is the requested name, before unique naming. Return a non-nil error to
+// deny with 403, or one wrapping ErrUnauthenticated to deny with 401; its message is sent to the client.
+type AuthorizeFunc func(r *http.Request, op gcsurl.Operation, bucketName, objectName string) error
+
+// Options configures a Handler
+type Options struct {
+	// Authorize is called before every URL is signed; nil allows everything
+	Authorize AuthorizeFunc
+	// Buckets lists the buckets clients may name besides the generator's default bucket
+	Buckets []string
+	// MaxExpiry caps the expiry clients may request (default: the generator's default expiry)
+	MaxExpiry time.Duration
+	// MaxBatchSize caps the number of uploads and downloads in one batch request (default: 100)
+	MaxBatchSize int
+	// MaxBodyBytes caps the size of a request body (default: 1 MiB)
+	MaxBodyBytes int64
+	// ErrorLog receives errors answered with 500; the log package's standard logger is used when nil
+	ErrorLog *log.Logger
+}
+
+// UploadRequest is the body of an upload request
+type UploadRequest struct {
+	ObjectName    string            `json:"objectName"`              // Requested file name, e.g. "reports/q1.pdf"
+	Bucket        string            `json:"bucket,omitempty"`        // Default bucket when empty
+	ExpirySeconds int               `json:"expirySeconds,omitempty"` // Default expiry when zero
+	Metadata      map[string]string `json:"metadata,omitempty"`      // Signed as x-goog-meta-* headers
+	Checksum      *gcsurl.Checksum  `json:"checksum,omitempty"`      // Binds the upload to an MD5 and/or CRC32C
+	ContentHash   string            `json:"contentHash,omitempty"`   // Hex digest for the ContentHashNames strategy
+	KeyValues     map[string]string `json:"keyValues,omitempty"`     // Fills the custom placeholders of Config.KeyTemplate
+}
+
+// DownloadRequest is the body of a download request
+type DownloadRequest struct {
+	ObjectName    string `json:"objectName"`              // Object key, e.g. a stored GeneratedKey
+	Bucket        string `json:"bucket,omitempty"`        // Default bucket when empty
+	ExpirySeconds int    `json:"expirySeconds,omitempty"` // Default expiry when zero
+	Disposition   string `json:"disposition,omitempty"`   // "attachment" or "inline"
+	Filename      string `json:"filename,omitempty"`      // Name the browser saves the file as
+}
+
+// BatchRequest is the body of a batch request
+type BatchRequest struct {
+	Uploads   []UploadRequest   `json:"uploads,omitempty"`
+	Downloads []DownloadRequest `json:"downloads,omitempty"`
+}
+
+// BatchResponse holds one result per batch item, in request order
+type BatchResponse struct {
+	Uploads   []UploadResult   `json:"uploads"`
+	Downloads []DownloadResult `json:"downloads"`
+}
+
+// UploadResult is the outcome of one batch upload item; exactly one of Upload and Error is set
+type UploadResult struct {
+	Upload *gcsurl.DocumentUpload `json:"upload,omitempty"`
+	Error  *ErrorResponse         `json:"error,omitempty"`
+}
+
+// DownloadResult is the outcome of one batch download item; exactly one of Download and Error is set
+type DownloadResult struct {
+	Download *gcsurl.DocumentDownload `json:"download,omitempty"`
+	Error    *ErrorResponse           `json:"error,omitempty"`
+}
+
+// ErrorResponse is the body of an error answer, and the error of a failed batch item
+type ErrorResponse struct {
+	Status  int    `json:"status"`  // HTTP status code
+	Message string `json:"message"` // Human readable reason
+}
+
+// Handler serves the upload, download and batch endpoints
+type Handler struct {
+	generator gcsurl.URLSigner
+	options   Options
+	mux       *http.ServeMux
+}
+
+// New creates a Handler that signs URLs with generator, usually a *gcsurl.URLGenerator
+func New(generator gcsurl.URLSigner, options Options) *Handler {
+	if options.MaxExpiry <= 0 {
+		options.MaxExpiry = generator.GetDefaultExpiry()
+	}
+	if options.MaxBatchSize <= 0 {
+		options.MaxBatchSize = defaultMaxBatchSize
+	}
+	if options.MaxBodyBytes <= 0 {
+		options.MaxBodyBytes = defaultMaxBodyBytes
+	}
+
+	h := &Handler{generator: generator, options: options, mux: http.NewServeMux()}
+	h.mux.HandleFunc("POST /upload", h.ServeUpload)
+	h.mux.HandleFunc("POST /download", h.ServeDownload)
+	h.mux.HandleFunc("POST /batch", h.ServeBatch)
+	return h
+}
+
+// ServeHTTP routes /upload, /download and /batch; use http.StripPrefix to mount it under a prefix
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// ServeUpload answers an UploadRequest with a gcsurl.DocumentUpload
+func (h *Handler) ServeUpload(w http.ResponseWriter, r *http.Request) {
+	var req UploadRequest
+	if err := h.decode(w, r, &req); err != nil {
+		h.writeError(w, err)
+		return
+	}
+	upload, err := h.upload(r, req)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, upload)
+}
+
+// ServeDownload answers a DownloadRequest with a gcsurl.DocumentDownload
+func (h *Handler) ServeDownload(w http.ResponseWriter, r *http.Request) {
+	var req DownloadRequest
+	if err := h.decode(w, r, &req); err != nil {
+		h.writeError(w, err)
+		return
+	}
+	download, err := h.download(r, req)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, download)
+}
+
+// ServeBatch answers a BatchRequest with a BatchResponse
+// Items fail independently: the answer is 200 and failed items carry their own error.
+func (h *Handler) ServeBatch(w http.ResponseWriter, r *http.Request) {
+	var req BatchRequest
+	if err := h.decode(w, r, &req); err != nil {
+		h.writeError(w, err)
+		return
+	}
+	items := len(req.Uploads) + len(req.Downloads)
+	if items == 0 {
+		h.writeError(w, badRequest("batch must contain at least one upload or download"))
+		return
+	}
+	if items > h.options.MaxBatchSize {
+		h.writeError(w, badRequest("batch has %d items, more than the maximum of %d", items, h.options.MaxBatchSize))
+		return
+	}
+
+	resp := BatchResponse{
+		Uploads:   make([]UploadResult, len(req.Uploads)),
+		Downloads: make([]DownloadResult, len(req.Downloads)),
+	}
+	for i, item := range req.Uploads {
+		upload, err := h.upload(r, item)
+		if err != nil {
+			resp.Uploads[i].Error = h.errorResponse(err)
+			continue
+		}
+		resp.Uploads[i].Upload = &upload
+	}
+	for i, item := range req.Downloads {
+		download, err := h.download(r, item)
+		if err != nil {
+			resp.Downloads[i].Error = h.errorResponse(err)
+			continue
+		}
+		resp.Downloads[i].Download = &download
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// upload authorizes and signs one upload request
+func (h *Handler) upload(r *http.Request, req UploadRequest) (gcsurl.DocumentUpload, error) {
+	bucketName, expiry, err := h.target(req.Bucket, req.ObjectName, req.ExpirySeconds)
+	if err != nil {
+		return gcsurl.DocumentUpload{}, err
+	}
+	if err := h.authorize(r, gcsurl.OperationUpload, bucketName, req.ObjectName); err != nil {
+		return gcsurl.DocumentUpload{}, err
+	}
+
+	options := gcsurl.UploadOptions{
+		Expiry:      expiry,
+		Metadata:    req.Metadata,
+		ContentHash: req.ContentHash,
+		KeyValues:   req.KeyValues,
+	}
+	if req.Checksum != nil {
+		options.Checksum = *req.Checksum
+	}
+	return h.generator.GenerateSignedUploadURLWithOptions(r.Context(), bucketName, req.ObjectName, options)
+}
+
+// download authorizes and signs one download request
+func (h *Handler) download(r *http.Request, req DownloadRequest) (gcsurl.DocumentDownload, error) {
+	bucketName, expiry, err := h.target(req.Bucket, req.ObjectName, req.ExpirySeconds)
+	if err != nil {
+		return gcsurl.DocumentDownload{}, err
+	}
+	if err := h.authorize(r, gcsurl.OperationDownload, bucketName, req.ObjectName); err != nil {
+		return gcsurl.DocumentDownload{}, err
+	}
+
+	return h.generator.GenerateSignedDownloadWithOptions(r.Context(), bucketName, req.ObjectName, gcsurl.DownloadOptions{
+		Expiry:      expiry,
+		Disposition: req.Disposition,
+		Filename:    req.Filename,
+	})
+}
+
+// target validates the bucket, object name and expiry of a request
+func (h *Handler) target(bucketName, objectName string, expirySeconds int) (string, time.Duration, error) {
+	if objectName == "" {
+		return "", 0, badRequest("objectName is required")
+	}
+	if bucketName == "" {
+		bucketName = h.generator.GetBucketName()
+	} else if bucketName != h.generator.GetBucketName() && !slices.Contains(h.options.Buckets, bucketName) {
+		return "", 0, badRequest("bucket %q is not allowed", bucketName)
+	}
+
+	if expirySeconds < 0 {
+		return "", 0, badRequest("expirySeconds must be positive, got %d", expirySeconds)
+	}
+	expiry := time.Duration(expirySeconds) * time.Second
+	if expiry > h.options.MaxExpiry {
+		return "", 0, badRequest("expirySeconds must be at most %d, got %d", int(h.options.MaxExpiry.Seconds()), expirySeconds)
+	}
+	return bucketName, expiry, nil
+}
+
+// authorize runs the authorization callback and marks denials with their status
+func (h *Handler) authorize(r *http.Request, op gcsurl.Operation, bucketName, objectName string) error {
+	if h.options.Authorize == nil {
+		return nil
+	}
+	err := h.options.Authorize(r, op, bucketName, objectName)
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, ErrUnauthenticated) {
+		return &statusError{status: http.StatusUnauthorized, err: err}
+	}
+	return &statusError{status: http.StatusForbidden, err: err}
+}
+
+// decode reads a JSON request body into v
+func (h *Handler) decode(w http.ResponseWriter, r *http.Request, v any) error {
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || mediaType != "application/json" {
+			return &statusError{status: http.StatusUnsupportedMediaType, err: fmt.Errorf("content type must be application/json, got %q", contentType)}
+		}
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, h.options.MaxBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return &statusError{status: http.StatusRequestEntityTooLarge, err: fmt.Errorf("request body is larger than %d bytes", maxBytesErr.Limit)}
+		}
+		return badRequest("invalid JSON request body: %v", err)
+	}
+	if decoder.More() {
+		return badRequest("request body must contain a single JSON object")
+	}
+	return nil
+}
+
+// statusError is an error answered with a specific status code
+type statusError struct {
+	status int
+	err    error
+}
+
+func (e *statusError) Error() string { return e.err.Error() }
+
+func (e *statusError) Unwrap() error { return e.err }
+
+// badRequest formats an error answered with 400
+func badRequest(format string, args ...any) error {
+	return &statusError{status: http.StatusBadRequest, err: fmt.Errorf(format, args...)}
+}
+
+// errorResponse maps an error to its status code and client-facing message
+// Input errors from gcsurl become 400; anything else is logged and hidden behind a 500.
+func (h *Handler) errorResponse(err error) *ErrorResponse {
+	var statusErr *statusError
+	switch {
+	case errors.As(err, &statusErr):
+		return &ErrorResponse{Status: statusErr.status, Message: err.Error()}
+	case errors.Is(err, gcsurl.ErrInvalidInput):
+		return &ErrorResponse{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	if h.options.ErrorLog != nil {
+		h.options.ErrorLog.Printf("httphandler: %v", err)
+	} else {
+		log.Printf("httphandler: %v", err)
+	}
+	return &ErrorResponse{Status: http.StatusInternalServerError, Message: "failed to sign URL"}
+}
+
+// writeError answers with the status and message of err
+func (h *Handler) writeError(w http.ResponseWriter, err error) {
+	resp := h.errorResponse(err)
+	writeJSON(w, resp.Status, resp)
+}
+
+// writeJSON answers with v encoded as JSON
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,318 @@
+package httphandler_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tropikoearth/gcsurl"
+	"github.com/tropikoearth/gcsurl/gcsurltest"
+	"github.com/tropikoearth/gcsurl/httphandler"
+)
+
+// newHandler returns a handler signing URLs for a gcsurltest server
+func newHandler(t *testing.T, options httphandler.Options) http.Handler {
+	t.Helper()
+	server, err := gcsurltest.NewServer(gcsurltest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+
+	config := server.Config("documents")
+	config.UploadRestrictions = &gcsurl.UploadRestrictions{AllowedExtensions: []string{".pdf"}, AllowMultiple: true}
+	generator, err := gcsurl.NewURLGeneratorWithConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return httphandler.New(generator, options)
+}
+
+// serve sends a request to the handler and decodes the JSON answer into v
+func serve(t *testing.T, handler http.Handler, method, target, contentType, body string, v any) int {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if v != nil {
+		if err := json.NewDecoder(rec.Body).Decode(v); err != nil && err != io.EOF {
+			t.Fatalf("%s %s: invalid JSON answer: %v", method, target, err)
+		}
+	}
+	return rec.Code
+}
+
+func TestUpload(t *testing.T) {
+	handler := newHandler(t, httphandler.Options{})
+
+	var upload gcsurl.DocumentUpload
+	status := serve(t, handler, http.MethodPost, "/upload", "application/json",
+		`{"objectName": "reports/q1.pdf", "metadata": {"owner": "u123"}, "expirySeconds": 60}`, &upload)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if !strings.HasPrefix(upload.GeneratedKey, "reports/") || !strings.HasSuffix(upload.GeneratedKey, "_q1.pdf") {
+		t.Errorf("GeneratedKey = %q", upload.GeneratedKey)
+	}
+	if upload.Headers["x-goog-meta-owner"] != "u123" || upload.Headers["Content-Type"] != "application/pdf" {
+		t.Errorf("Headers = %v", upload.Headers)
+	}
+	if remaining := time.Until(upload.ExpiresAt); remaining <= 0 || remaining > time.Minute {
+		t.Errorf("ExpiresAt is %v away, want at most a minute", remaining)
+	}
+}
+
+func TestUploadKeyValues(t *testing.T) {
+	server, err := gcsurltest.NewServer(gcsurltest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	config := server.Config("documents")
+	config.KeyTemplate = "{tenant}/{id}{ext}"
+	generator, err := gcsurl.NewURLGeneratorWithConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := httphandler.New(generator, httphandler.Options{})
+
+	var upload gcsurl.DocumentUpload
+	status := serve(t, handler, http.MethodPost, "/upload", "application/json",
+		`{"objectName": "q1.pdf", "keyValues": {"tenant": "acme"}}`, &upload)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if !strings.HasPrefix(upload.GeneratedKey, "acme/") || !strings.HasSuffix(upload.GeneratedKey, ".pdf") {
+		t.Errorf("GeneratedKey = %q, want acme/<id>.pdf", upload.GeneratedKey)
+	}
+
+	if status := serve(t, handler, http.MethodPost, "/upload", "application/json", `{"objectName": "q1.pdf"}`, nil); status != http.StatusBadRequest {
+		t.Errorf("missing key value: status = %d, want 400", status)
+	}
+}
+
+func TestUploadContentHash(t *testing.T) {
+	server, err := gcsurltest.NewServer(gcsurltest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	config := server.Config("documents")
+	config.NameStrategy = gcsurl.ContentHashNames()
+	generator, err := gcsurl.NewURLGeneratorWithConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := httphandler.New(generator, httphandler.Options{})
+
+	var upload gcsurl.DocumentUpload
+	status := serve(t, handler, http.MethodPost, "/upload", "application/json",
+		`{"objectName": "reports/q1.pdf", "contentHash": "abcdef01"}`, &upload)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if upload.GeneratedKey != "reports/abcdef01.pdf" || upload.Headers["x-goog-if-generation-match"] != "0" {
+		t.Errorf("GeneratedKey = %q, Headers = %v, want reports/abcdef01.pdf with x-goog-if-generation-match:0", upload.GeneratedKey, upload.Headers)
+	}
+}
+
+func TestDownload(t *testing.T) {
+	handler := newHandler(t, httphandler.Options{})
+
+	var download gcsurl.DocumentDownload
+	status := serve(t, handler, http.MethodPost, "/download", "application/json",
+		`{"objectName": "reports/a1b2c3d4_q1.pdf", "disposition": "attachment"}`, &download)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if download.Method != http.MethodGet || download.Bucket != "documents" || download.Object != "reports/a1b2c3d4_q1.pdf" {
+		t.Errorf("download = %+v", download)
+	}
+	if !strings.Contains(download.DownloadURL, "response-content-disposition=attachment") {
+		t.Errorf("DownloadURL = %s, want a signed Content-Disposition", download.DownloadURL)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	handler := newHandler(t, httphandler.Options{MaxBatchSize: 3})
+
+	var resp httphandler.BatchResponse
+	status := serve(t, handler, http.MethodPost, "/batch", "application/json", `{
+		"uploads": [{"objectName": "a.pdf"}, {"objectName": "b.exe"}],
+		"downloads": [{"objectName": "reports/a.pdf"}]
+	}`, &resp)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if len(resp.Uploads) != 2 || len(resp.Downloads) != 1 {
+		t.Fatalf("response = %+v", resp)
+	}
+	if resp.Uploads[0].Upload == nil || resp.Uploads[0].Error != nil {
+		t.Errorf("uploads[0] = %+v, want an upload", resp.Uploads[0])
+	}
+	if resp.Uploads[1].Error == nil || resp.Uploads[1].Error.Status != http.StatusBadRequest {
+		t.Errorf("uploads[1] = %+v, want a 400 error", resp.Uploads[1])
+	}
+	if resp.Downloads[0].Download == nil {
+		t.Errorf("downloads[0] = %+v, want a download", resp.Downloads[0])
+	}
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"empty", `{}`},
+		{"too many items", `{"uploads": [{"objectName": "a.pdf"}, {"objectName": "b.pdf"}], "downloads": [{"objectName": "c.pdf"}, {"objectName": "d.pdf"}]}`},
+	}
+	for _, tt := range tests {
+		var errResp httphandler.ErrorResponse
+		if status := serve(t, handler, http.MethodPost, "/batch", "application/json", tt.body, &errResp); status != http.StatusBadRequest {
+			t.Errorf("%s batch: status = %d, want 400", tt.name, status)
+		}
+	}
+}
+
+func TestRequestValidation(t *testing.T) {
+	handler := newHandler(t, httphandler.Options{Buckets: []string{"archive"}, MaxBodyBytes: 256})
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"GET upload", http.MethodGet, "/upload", "", "", http.StatusMethodNotAllowed},
+		{"PUT download", http.MethodPut, "/download", "application/json", `{}`, http.StatusMethodNotAllowed},
+		{"unknown path", http.MethodPost, "/sign", "application/json", `{}`, http.StatusNotFound},
+		{"form body", http.MethodPost, "/upload", "application/x-www-form-urlencoded", "objectName=a.pdf", http.StatusUnsupportedMediaType},
+		{"invalid JSON", http.MethodPost, "/upload", "application/json", `{"objectName":`, http.StatusBadRequest},
+		{"unknown field", http.MethodPost, "/upload", "application/json", `{"objectName": "a.pdf", "acl": "public-read"}`, http.StatusBadRequest},
+		{"two objects", http.MethodPost, "/upload", "application/json", `{"objectName": "a.pdf"} {}`, http.StatusBadRequest},
+		{"too large", http.MethodPost, "/upload", "application/json", `{"objectName": "` + strings.Repeat("a", 300) + `.pdf"}`, http.StatusRequestEntityTooLarge},
+		{"missing object", http.MethodPost, "/upload", "application/json", `{}`, http.StatusBadRequest},
+		{"disallowed bucket", http.MethodPost, "/upload", "application/json", `{"objectName": "a.pdf", "bucket": "private"}`, http.StatusBadRequest},
+		{"allowed bucket", http.MethodPost, "/upload", "application/json", `{"objectName": "a.pdf", "bucket": "archive"}`, http.StatusOK},
+		{"negative expiry", http.MethodPost, "/upload", "application/json", `{"objectName": "a.pdf", "expirySeconds": -1}`, http.StatusBadRequest},
+		{"expiry above maximum", http.MethodPost, "/download", "application/json", `{"objectName": "a.pdf", "expirySeconds": 86400}`, http.StatusBadRequest},
+		{"no content type", http.MethodPost, "/download", "", `{"objectName": "a.pdf"}`, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := serve(t, handler, tt.method, tt.target, tt.contentType, tt.body, nil); status != tt.want {
+				t.Errorf("status = %d, want %d", status, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvalidInputIsBadRequest(t *testing.T) {
+	handler := newHandler(t, httphandler.Options{})
+
+	tests := []struct {
+		name   string
+		target string
+		body   string
+	}{
+		{"disallowed extension", "/upload", `{"objectName": "setup.exe"}`},
+		{"path traversal", "/upload", `{"objectName": "../other/a.pdf"}`},
+		{"invalid metadata key", "/upload", `{"objectName": "a.pdf", "metadata": {"bad key": "x"}}`},
+		{"invalid checksum", "/upload", `{"objectName": "a.pdf", "checksum": {"md5": "not base64"}}`},
+		{"invalid disposition", "/download", `{"objectName": "a.pdf", "disposition": "open"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errResp httphandler.ErrorResponse
+			if status := serve(t, handler, http.MethodPost, tt.target, "application/json", tt.body, &errResp); status != http.StatusBadRequest {
+				t.Errorf("status = %d (%s), want 400", status, errResp.Message)
+			}
+			if errResp.Status != http.StatusBadRequest || errResp.Message == "" {
+				t.Errorf("error response = %+v", errResp)
+			}
+		})
+	}
+}
+
+func TestInternalErrorIsHidden(t *testing.T) {
+	var logged strings.Builder
+	generator := &gcsurltest.FakeGenerator{Err: errors.New("signBlob: permission denied")}
+	handler := httphandler.New(generator, httphandler.Options{ErrorLog: log.New(&logged, "", 0)})
+
+	var errResp httphandler.ErrorResponse
+	if status := serve(t, handler, http.MethodPost, "/upload", "application/json", `{"objectName": "a.pdf"}`, &errResp); status != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", status)
+	}
+	if strings.Contains(errResp.Message, "permission denied") {
+		t.Errorf("message %q leaks the internal error", errResp.Message)
+	}
+	if !strings.Contains(logged.String(), "permission denied") {
+		t.Errorf("internal error was not logged: %q", logged.String())
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	type check struct {
+		op         gcsurl.Operation
+		bucketName string
+		objectName string
+	}
+	var checks []check
+	handler := newHandler(t, httphandler.Options{
+		Authorize: func(r *http.Request, op gcsurl.Operation, bucketName, objectName string) error {
+			checks = append(checks, check{op, bucketName, objectName})
+			switch r.Header.Get("Authorization") {
+			case "":
+				return httphandler.ErrUnauthenticated
+			case "Bearer reader":
+				if op != gcsurl.OperationDownload {
+					return errors.New("readers cannot upload")
+				}
+			}
+			return nil
+		},
+	})
+
+	tests := []struct {
+		name          string
+		authorization string
+		target        string
+		want          int
+	}{
+		{"anonymous", "", "/upload", http.StatusUnauthorized},
+		{"reader upload", "Bearer reader", "/upload", http.StatusForbidden},
+		{"reader download", "Bearer reader", "/download", http.StatusOK},
+		{"writer upload", "Bearer writer", "/upload", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks = nil
+			req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(`{"objectName": "reports/q1.pdf"}`))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if len(checks) != 1 || checks[0].bucketName != "documents" || checks[0].objectName != "reports/q1.pdf" {
+				t.Errorf("Authorize calls = %+v", checks)
+			}
+		})
+	}
+}
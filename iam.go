@@ -0,0 +1,191 @@
+package gcsurl
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/compute/metadata"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// DefaultIAMCredentialsEndpoint is the base URL of the IAM Credentials API
+const DefaultIAMCredentialsEndpoint = "https://iamcredentials.googleapis.com"
+
+// iamScope is the OAuth2 scope required to call the IAM Credentials API
+const iamScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// iamTimeout bounds IAM calls made without a caller-provided context
+const iamTimeout = 30 * time.Second
+
+// IAMSigner signs blobs through the IAM Credentials signBlob API.
+// It is used when no service account private key is available, e.g. on GKE or
+// Cloud Run with Workload Identity, where only default credentials exist.
+type IAMSigner struct {
+	endpoint string
+	email    string
+
+	mu         sync.Mutex
+	httpClient *http.Client
+}
+
+// NewIAMSigner creates an IAM signer. Empty email means the runtime service
+// account is discovered on first use; a nil client means an OAuth2 client is
+// built from default credentials on first use.
+func NewIAMSigner(email, endpoint string, client *http.Client) *IAMSigner {
+	if endpoint == "" {
+		endpoint = DefaultIAMCredentialsEndpoint
+	}
+	return &IAMSigner{
+		endpoint:   strings.TrimSuffix(endpoint, "/"),
+		email:      email,
+		httpClient: client,
+	}
+}
+
+// AccessID returns the service account email, discovering it if needed
+func (s *IAMSigner) AccessID() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), iamTimeout)
+	defer cancel()
+	return s.AccessIDContext(ctx)
+}
+
+// Sign signs b through the signBlob API
+func (s *IAMSigner) Sign(b []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), iamTimeout)
+	defer cancel()
+	return s.SignContext(ctx, b)
+}
+
+// AccessIDContext returns the email signatures are made for, discovering it if needed
+// Discovery order: explicit email > client_email in default credentials > metadata server
+func (s *IAMSigner) AccessIDContext(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	email := s.email
+	s.mu.Unlock()
+	if email != "" {
+		return email, nil
+	}
+
+	// Discovery may call the metadata server, so it runs without the lock; concurrent first
+	// calls discover the same email and the first one stored wins
+	email, err := discoverServiceAccountEmail(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.email == "" {
+		s.email = email
+	}
+	return s.email, nil
+}
+
+// discoverServiceAccountEmail finds the runtime service account email
+func discoverServiceAccountEmail(ctx context.Context) (string, error) {
+	creds, err := google.FindDefaultCredentials(ctx, iamScope)
+	if err == nil && len(creds.JSON) > 0 {
+		var sa ServiceAccount
+		if json.Unmarshal(creds.JSON, &sa) == nil && sa.ClientEmail != "" {
+			return sa.ClientEmail, nil
+		}
+	}
+
+	email, err := metadata.EmailWithContext(ctx, "default")
+	if err != nil {
+		return "", fmt.Errorf("failed to discover service account email from metadata server: %w", err)
+	}
+	return email, nil
+}
+
+// client returns the authenticated HTTP client used to call the IAM Credentials API
+func (s *IAMSigner) client(ctx context.Context) (*http.Client, error) {
+	s.mu.Lock()
+	client := s.httpClient
+	s.mu.Unlock()
+	if client != nil {
+		return client, nil
+	}
+
+	// Finding default credentials may probe the metadata server, so it runs without the lock
+	tokenSource, err := google.DefaultTokenSource(ctx, iamScope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find default credentials for IAM signing: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.httpClient == nil {
+		// The client outlives this call, so it must not be bound to the request context
+		s.httpClient = oauth2.NewClient(context.Background(), tokenSource)
+	}
+	return s.httpClient, nil
+}
+
+// signBlobRequest is the request body of the signBlob API
+type signBlobRequest struct {
+	Payload string `json:"payload"`
+}
+
+// signBlobResponse is the response body of the signBlob API
+type signBlobResponse struct {
+	KeyID      string `json:"keyId"`
+	SignedBlob string `json:"signedBlob"`
+}
+
+// SignContext signs payload with the service account's Google-managed key
+func (s *IAMSigner) SignContext(ctx context.Context, payload []byte) ([]byte, error) {
+	email, err := s.AccessIDContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client, err := s.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(signBlobRequest{Payload: base64.StdEncoding.EncodeToString(payload)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode signBlob request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/projects/-/serviceAccounts/%s:signBlob", s.endpoint, email)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create signBlob request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("signBlob request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signBlob response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("signBlob for %s failed with status %d: %s", email, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var result signBlobResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse signBlob response: %w", err)
+	}
+	signature, err := base64.StdEncoding.DecodeString(result.SignedBlob)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode signBlob signature: %w", err)
+	}
+	return signature, nil
+}
@@ -0,0 +1,62 @@
+package gcsurl
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestIAMSignerSignContext(t *testing.T) {
+	const email = "signer@my-project.iam.gserviceaccount.com"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/projects/-/serviceAccounts/"+email+":signBlob" {
+			http.Error(w, "unexpected request "+r.Method+" "+r.URL.Path, http.StatusNotFound)
+			return
+		}
+		var req signBlobRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		payload, _ := base64.StdEncoding.DecodeString(req.Payload)
+		json.NewEncoder(w).Encode(signBlobResponse{
+			KeyID:      "key-1",
+			SignedBlob: base64.StdEncoding.EncodeToString(append([]byte("signed:"), payload...)),
+		})
+	}))
+	defer server.Close()
+
+	signer := NewIAMSigner(email, server.URL+"/", server.Client())
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			signature, err := signer.SignContext(context.Background(), []byte("payload"))
+			if err != nil || string(signature) != "signed:payload" {
+				t.Errorf("SignContext() = %q, %v", signature, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if accessID, err := signer.AccessID(); err != nil || accessID != email {
+		t.Errorf("AccessID() = %q, %v, want %q", accessID, err, email)
+	}
+}
+
+func TestIAMSignerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": {"status": "PERMISSION_DENIED"}}`, http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	signer := NewIAMSigner("signer@my-project.iam.gserviceaccount.com", server.URL, server.Client())
+	if _, err := signer.Sign([]byte("payload")); err == nil {
+		t.Error("Sign() succeeded, want the signBlob error")
+	}
+}
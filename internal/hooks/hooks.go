@@ -0,0 +1,7 @@
+// Package hooks shares unexported gcsurl helpers with the other packages of this module
+// The gcsurl package sets the hooks in its init function, so they are ready for any
+// package importing gcsurl.
+package hooks
+
+// UploadHeaders returns the headers that follow from a gcsurl.UploadOptions alone
+var UploadHeaders func(options any) (map[string]string, error)
@@ -0,0 +1,185 @@
+package gcsurl
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// builtinPlaceholders are filled by the library; any other placeholder comes from NameInput.Values
+var builtinPlaceholders = map[string]bool{
+	"id": true, "uuid": true, "uuidv7": true,
+	"dir": true, "filename": true, "name": true, "ext": true,
+	"yyyy": true, "mm": true, "dd": true, "hh": true,
+}
+
+// KeyTemplate builds object keys from a template such as "{tenant}/{yyyy}/{mm}/{id}{ext}"
+//
+// Built-in placeholders:
+//   - {id}, {uuid}, {uuidv7}: short random ID, random UUID, time-ordered UUID
+//   - {dir}: directory of the requested name, without trailing slash
+//   - {filename}, {name}, {ext}: file name, without extension, and lowercase extension (".pdf")
+//   - {yyyy}, {mm}, {dd}, {hh}: UTC date and hour
+//
+// Any other placeholder is filled from the per-request values (UploadOptions.KeyValues).
+// The file name is used as the generator passes it in, after Config.Sanitizer has cleaned it.
+type KeyTemplate struct {
+	template string
+	segments []templateSegment
+}
+
+// templateSegment is literal text or a placeholder name
+type templateSegment struct {
+	text        string
+	placeholder bool
+}
+
+// NewKeyTemplate parses and validates a key template
+// The template must contain {id}, {uuid} or {uuidv7} so generated keys are unique.
+func NewKeyTemplate(template string) (*KeyTemplate, error) {
+	if template == "" {
+		return nil, fmt.Errorf("key template cannot be empty")
+	}
+	if strings.HasPrefix(template, "/") {
+		return nil, fmt.Errorf("key template %q must not start with /", template)
+	}
+
+	t := &KeyTemplate{template: template}
+	unique := false
+	rest := template
+	for rest != "" {
+		open := strings.IndexAny(rest, "{}")
+		if open < 0 {
+			t.segments = append(t.segments, templateSegment{text: rest})
+			break
+		}
+		if rest[open] == '}' {
+			return nil, fmt.Errorf("key template %q has an unmatched }", template)
+		}
+		if open > 0 {
+			t.segments = append(t.segments, templateSegment{text: rest[:open]})
+		}
+		end := strings.IndexAny(rest[open+1:], "{}")
+		if end < 0 || rest[open+1+end] != '}' {
+			return nil, fmt.Errorf("key template %q has an unclosed {", template)
+		}
+		name := rest[open+1 : open+1+end]
+		if !isPlaceholderName(name) {
+			return nil, fmt.Errorf("key template %q has an invalid placeholder {%s}", template, name)
+		}
+		if name == "id" || name == "uuid" || name == "uuidv7" {
+			unique = true
+		}
+		t.segments = append(t.segments, templateSegment{text: name, placeholder: true})
+		rest = rest[open+end+2:]
+	}
+
+	// Check path segments with every placeholder standing in for a value
+	var probe strings.Builder
+	for _, segment := range t.segments {
+		if segment.placeholder {
+			probe.WriteString("x")
+		} else {
+			probe.WriteString(segment.text)
+		}
+	}
+	if strings.Contains(probe.String(), "//") || containsDotSegment(probe.String()) {
+		return nil, fmt.Errorf("key template %q must not contain empty, . or .. path segments", template)
+	}
+	if !unique {
+		return nil, fmt.Errorf("key template %q must contain {id}, {uuid} or {uuidv7}", template)
+	}
+	return t, nil
+}
+
+// String returns the template text
+func (t *KeyTemplate) String() string {
+	return t.template
+}
+
+// Placeholders returns the placeholders that must be supplied per request
+func (t *KeyTemplate) Placeholders() []string {
+	var names []string
+	for _, segment := range t.segments {
+		if segment.placeholder && !builtinPlaceholders[segment.text] {
+			names = append(names, segment.text)
+		}
+	}
+	return names
+}
+
+// GenerateName fills the template for a requested object name
+func (t *KeyTemplate) GenerateName(input NameInput) (string, error) {
+	dir, filename := path.Split(input.ObjectName)
+	ext := strings.ToLower(path.Ext(filename))
+
+	var b strings.Builder
+	for _, segment := range t.segments {
+		if !segment.placeholder {
+			b.WriteString(segment.text)
+			continue
+		}
+
+		var value string
+		var err error
+		switch segment.text {
+		case "id":
+			value, err = generateShortUUID()
+		case "uuid":
+			value, err = newUUID(4, input.Time)
+		case "uuidv7":
+			value, err = newUUID(7, input.Time)
+		case "dir":
+			value = strings.Trim(dir, "/")
+		case "filename":
+			value = filename
+		case "name":
+			value = strings.TrimSuffix(filename, path.Ext(filename))
+		case "ext":
+			value = ext
+		case "yyyy":
+			value = input.Time.Format("2006")
+		case "mm":
+			value = input.Time.Format("01")
+		case "dd":
+			value = input.Time.Format("02")
+		case "hh":
+			value = input.Time.Format("15")
+		default:
+			var ok bool
+			if value, ok = input.Values[segment.text]; !ok || value == "" {
+				return "", inputErrorf("key template value {%s} is required", segment.text)
+			}
+			if strings.Contains(value, "/") || value == "." || value == ".." || strings.ContainsAny(value, "\r\n") {
+				return "", inputErrorf("key template value {%s} must be a single path segment, got %q", segment.text, value)
+			}
+		}
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(value)
+	}
+
+	// An empty {dir} must not leave a leading or doubled slash behind
+	key := strings.TrimPrefix(b.String(), "/")
+	for strings.Contains(key, "//") {
+		key = strings.ReplaceAll(key, "//", "/")
+	}
+	if containsDotSegment(key) {
+		return "", inputErrorf("object key %q must not contain . or .. path segments", key)
+	}
+	return key, nil
+}
+
+// isPlaceholderName reports whether name is a valid placeholder name
+func isPlaceholderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '_' && c != '-' {
+			return false
+		}
+	}
+	return true
+}
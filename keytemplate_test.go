@@ -0,0 +1,133 @@
+package gcsurl_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tropikoearth/gcsurl"
+)
+
+func TestNewKeyTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		wantErr  bool
+	}{
+		{"{tenant}/{yyyy}/{mm}/{id}{ext}", false},
+		{"{dir}/{uuidv7}_{filename}", false},
+		{"", true},
+		{"/{id}", true},
+		{"{tenant}/{name}{ext}", true},
+		{"{id", true},
+		{"id}", true},
+		{"{bad name}/{id}", true},
+		{"a//{id}", true},
+		{"../{id}", true},
+	}
+
+	for _, tt := range tests {
+		if _, err := gcsurl.NewKeyTemplate(tt.template); (err != nil) != tt.wantErr {
+			t.Errorf("NewKeyTemplate(%q) error = %v, wantErr %v", tt.template, err, tt.wantErr)
+		}
+	}
+}
+
+func TestKeyTemplateGenerateName(t *testing.T) {
+	at := time.Date(2025, time.March, 7, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		template   string
+		objectName string
+		values     map[string]string
+		want       string // Regexp the whole key must match
+		wantErr    bool
+	}{
+		{"date partitions", "{tenant}/{yyyy}/{mm}/{dd}/{hh}/{id}{ext}", "Report.PDF", map[string]string{"tenant": "acme"}, `^acme/2025/03/07/09/[0-9a-f]{8}\.pdf$`, false},
+		{"directory and name", "{dir}/{uuidv7}_{filename}", "users/123/cv.pdf", nil, `^users/123/[0-9a-f-]{36}_cv\.pdf$`, false},
+		{"empty directory", "{dir}/{uuid}_{name}{ext}", "cv.pdf", nil, `^[0-9a-f-]{36}_cv\.pdf$`, false},
+		{"name kept as passed in", "{id}_{name}{ext}", "Müller Straße.PDF", nil, `^[0-9a-f]{8}_Müller Straße\.pdf$`, false},
+		{"missing value", "{tenant}/{id}", "a.pdf", nil, "", true},
+		{"value with slash", "{tenant}/{id}", "a.pdf", map[string]string{"tenant": "acme/other"}, "", true},
+		{"dot-dot value", "{tenant}/{id}", "a.pdf", map[string]string{"tenant": ".."}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template, err := gcsurl.NewKeyTemplate(tt.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			key, err := template.GenerateName(gcsurl.NameInput{ObjectName: tt.objectName, Time: at, Values: tt.values})
+			if tt.wantErr {
+				if !errors.Is(err, gcsurl.ErrInvalidInput) {
+					t.Errorf("GenerateName() = %q, %v, want ErrInvalidInput", key, err)
+				}
+				return
+			}
+			if err != nil || !regexp.MustCompile(tt.want).MatchString(key) {
+				t.Errorf("GenerateName() = %q, %v, want a match for %s", key, err, tt.want)
+			}
+		})
+	}
+}
+
+// dashSanitizer is a custom Sanitizer replacing spaces with dashes
+type dashSanitizer struct{}
+
+func (dashSanitizer) SanitizeFilename(filename string) string {
+	return strings.ReplaceAll(filename, " ", "-")
+}
+
+func TestKeyTemplateSanitizer(t *testing.T) {
+	signer, err := gcsurl.NewTestSigner("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		sanitizer gcsurl.Sanitizer
+		want      string // Regexp the whole key must match
+	}{
+		{"default", nil, `^acme/[0-9a-f]{8}_Muller_Strasse\.pdf$`},
+		{"percent encoding", gcsurl.FilenameSanitizer{Mode: gcsurl.SanitizePercentEncode}, `^acme/[0-9a-f]{8}_M%C3%BCller%20Stra%C3%9Fe\.pdf$`},
+		{"unicode", gcsurl.FilenameSanitizer{Mode: gcsurl.SanitizeUnicode}, `^acme/[0-9a-f]{8}_Müller Straße\.pdf$`},
+		{"custom", dashSanitizer{}, `^acme/[0-9a-f]{8}_Müller-Straße\.pdf$`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator, err := gcsurl.NewURLGeneratorWithConfig(gcsurl.Config{
+				BucketName:  "documents",
+				Signer:      signer,
+				KeyTemplate: "{tenant}/{id}_{name}{ext}",
+				Sanitizer:   tt.sanitizer,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			upload, err := generator.GenerateSignedUploadURLWithOptions(context.Background(), "documents", "Müller Straße.pdf", gcsurl.UploadOptions{
+				KeyValues: map[string]string{"tenant": "acme"},
+			})
+			if err != nil || !regexp.MustCompile(tt.want).MatchString(upload.GeneratedKey) {
+				t.Errorf("GeneratedKey = %q, %v, want a match for %s", upload.GeneratedKey, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyTemplatePlaceholders(t *testing.T) {
+	template, err := gcsurl.NewKeyTemplate("{tenant}/{yyyy}/{project}/{id}{ext}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := template.Placeholders(); !slices.Equal(got, []string{"tenant", "project"}) {
+		t.Errorf("Placeholders() = %v, want [tenant project]", got)
+	}
+	if template.String() != "{tenant}/{yyyy}/{project}/{id}{ext}" {
+		t.Errorf("String() = %q", template.String())
+	}
+}
@@ -0,0 +1,52 @@
+package gcsurl
+
+import "strings"
+
+// metadataHeaderPrefix is the header prefix of GCS custom metadata
+const metadataHeaderPrefix = "x-goog-meta-"
+
+// maxCustomMetadataBytes is the GCS limit on the total size of custom metadata keys and values
+const maxCustomMetadataBytes = 8 * 1024
+
+// metadataHeaders converts custom metadata into signed x-goog-meta-* headers
+// Keys are lowercased and may be given with or without the prefix. Values must be printable
+// ASCII, since browsers cannot send anything else as header values.
+func metadataHeaders(metadata map[string]string) (map[string]string, error) {
+	headers := make(map[string]string, len(metadata))
+	size := 0
+	for key, value := range metadata {
+		name := strings.TrimPrefix(strings.ToLower(key), metadataHeaderPrefix)
+		if name == "" {
+			return nil, inputErrorf("metadata key cannot be empty")
+		}
+		for _, c := range name {
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' && c != '_' {
+				return nil, inputErrorf("metadata key %q may only contain letters, digits, - and _", key)
+			}
+		}
+		header := metadataHeaderPrefix + name
+		if _, exists := headers[header]; exists {
+			return nil, inputErrorf("metadata key %q is given more than once", name)
+		}
+
+		if !isPrintableASCII(value) {
+			return nil, inputErrorf("metadata value for %q must be printable ASCII; encode it first, e.g. with url.PathEscape", name)
+		}
+		size += len(name) + len(value)
+		headers[header] = value
+	}
+	if size > maxCustomMetadataBytes {
+		return nil, inputErrorf("custom metadata is %d bytes, more than the maximum of %d", size, maxCustomMetadataBytes)
+	}
+	return headers, nil
+}
+
+// isPrintableASCII reports whether s only contains printable ASCII characters
+func isPrintableASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
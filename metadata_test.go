@@ -0,0 +1,49 @@
+package gcsurl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMetadataHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		want     map[string]string
+		wantErr  bool
+	}{
+		{"lowercased key", map[string]string{"Owner": "u123"}, map[string]string{"x-goog-meta-owner": "u123"}, false},
+		{"prefixed key", map[string]string{"X-Goog-Meta-Tenant": "acme"}, map[string]string{"x-goog-meta-tenant": "acme"}, false},
+		{"encoded value", map[string]string{"original-name": "Vertr%C3%A4ge%202025.pdf"}, map[string]string{"x-goog-meta-original-name": "Vertr%C3%A4ge%202025.pdf"}, false},
+		{"empty key", map[string]string{"x-goog-meta-": "x"}, nil, true},
+		{"invalid key", map[string]string{"bad key": "x"}, nil, true},
+		{"duplicate key", map[string]string{"owner": "a", "Owner": "b"}, nil, true},
+		{"non-ASCII value", map[string]string{"original-name": "Verträge 2025.pdf"}, nil, true},
+		{"control character", map[string]string{"note": "line\nbreak"}, nil, true},
+		{"too large", map[string]string{"blob": strings.Repeat("a", maxCustomMetadataBytes)}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers, err := metadataHeaders(tt.metadata)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidInput) {
+					t.Errorf("metadataHeaders() error = %v, want ErrInvalidInput", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(headers) != len(tt.want) {
+				t.Errorf("metadataHeaders() = %v, want %v", headers, tt.want)
+			}
+			for name, value := range tt.want {
+				if headers[name] != value {
+					t.Errorf("metadataHeaders()[%q] = %q, want %q", name, headers[name], value)
+				}
+			}
+		})
+	}
+}
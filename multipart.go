@@ -0,0 +1,346 @@
+package gcsurl
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// XML API multipart upload limits
+const (
+	minMultipartPartSize     = 5 * 1024 * 1024        // 5 MiB, except for the last part
+	maxMultipartPartSize     = 5 * 1024 * 1024 * 1024 // 5 GiB
+	maxMultipartParts        = 10000
+	defaultMultipartPartSize = 16 * 1024 * 1024
+)
+
+// MultipartUpload is a manifest the client drives to upload an object in parallel parts
+// Upload each part with a PUT to its URL and the given headers, keep the ETag response
+// headers, then POST the CompleteMultipartUpload XML to CompleteURL with CompleteHeaders
+// (or DELETE AbortURL).
+type MultipartUpload struct {
+	UploadID     string          `json:"uploadId"`     // XML API multipart upload ID
+	Bucket       string          `json:"bucket"`       // Bucket the object is uploaded to
+	GeneratedKey string          `json:"generatedKey"` // Unique file path for storage
+	OriginalName string          `json:"originalName"` // Original file name provided by user
+	TotalSize    int64           `json:"totalSize"`    // Declared object size in bytes
+	PartSize     int64           `json:"partSize"`     // Size of every part but the last
+	Parts        []MultipartPart `json:"parts"`        // Signed URLs for each part
+	CompleteURL  string          `json:"completeUrl"`  // Signed POST URL completing the upload
+	AbortURL     string          `json:"abortUrl"`     // Signed DELETE URL aborting the upload
+	ExpiresAt    time.Time       `json:"expiresAt"`    // When the signed URLs expire
+	// CompleteHeaders must be sent with the POST to CompleteURL (generation preconditions)
+	CompleteHeaders map[string]string `json:"completeHeaders,omitempty"`
+}
+
+// MultipartPart is a single part of a multipart upload
+type MultipartPart struct {
+	PartNumber int               `json:"partNumber"`        // 1-based part number
+	URL        string            `json:"url"`               // Signed PUT URL for the part
+	Offset     int64             `json:"offset"`            // Offset of the part in the file
+	Size       int64             `json:"size"`              // Exact size of the part in bytes
+	Headers    map[string]string `json:"headers,omitempty"` // Headers the client must send
+}
+
+// CompletedPart identifies an uploaded part when completing a multipart upload
+type CompletedPart struct {
+	PartNumber int    `json:"partNumber" xml:"PartNumber"`
+	ETag       string `json:"etag" xml:"ETag"`
+}
+
+// MultipartUploadOptions customizes a multipart upload
+type MultipartUploadOptions struct {
+	// PartSize is the size of each part; computed from the total size when zero
+	PartSize int64
+	// Expiry overrides the default expiry of the signed URLs when set
+	Expiry time.Duration
+	// Metadata is stored as custom metadata and signed into the initiation request
+	Metadata map[string]string
+	// DoesNotExist rejects the upload if the object already exists (x-goog-if-generation-match:0)
+	DoesNotExist bool
+	// IfGenerationMatch only lets the upload replace this generation of the object
+	// The precondition is signed into both the initiation and the completion request. It requires
+	// a naming strategy that keeps the object name, such as OriginalNames.
+	IfGenerationMatch int64
+	// ContentHash is the hex digest of the file, used by the ContentHashNames strategy
+	// As in UploadOptions, the upload is signed with x-goog-if-generation-match:0.
+	ContentHash string
+	// KeyValues fills the custom placeholders of Config.KeyTemplate (e.g. {"tenant": "acme"})
+	KeyValues map[string]string
+	// EncryptionKey encrypts the object with a CSEK or CMEK key instead of Config.EncryptionKeys
+	EncryptionKey *EncryptionKey
+	// Storage sets the storage class, caching, encoding, language and ACL of the object
+	// Fields set here override Config.BucketStorageOptions.
+	Storage StorageOptions
+}
+
+// initiateMultipartUploadResult is the XML response of a multipart upload initiation
+type initiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	UploadID string   `xml:"UploadId"`
+}
+
+// completeMultipartUpload is the XML request body completing a multipart upload
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []CompletedPart `xml:"Part"`
+}
+
+// CreateMultipartUpload initiates an XML API multipart upload in the default bucket
+// The part count is computed from totalSize, which is checked against the upload restrictions.
+// Restrictions and unique naming are applied exactly as in GenerateSignedUploadURL.
+func (u *URLGenerator) CreateMultipartUpload(ctx context.Context, objectName string, totalSize int64) (MultipartUpload, error) {
+	return u.CreateMultipartUploadWithOptions(ctx, u.bucketName, objectName, totalSize, MultipartUploadOptions{})
+}
+
+// CreateMultipartUploadWithOptions initiates an XML API multipart upload in a specific bucket with options
+// Every part URL is signed separately, so a remote signer such as the IAM signer makes one
+// signBlob call per part (up to 10,000). Set a larger PartSize to sign fewer URLs.
+func (u *URLGenerator) CreateMultipartUploadWithOptions(ctx context.Context, bucketName, objectName string, totalSize int64, options MultipartUploadOptions) (MultipartUpload, error) {
+	if totalSize <= 0 {
+		return MultipartUpload{}, inputErrorf("total size must be positive, got %d", totalSize)
+	}
+
+	headers := map[string]string{"Content-Type": "application/octet-stream"}
+	if u.hasRestrictions() {
+		if err := u.ValidateUpload(objectName); err != nil {
+			return MultipartUpload{}, err
+		}
+		if minSize, maxSize, ok := u.uploadRestrictions.contentLengthRange(); ok && (totalSize < minSize || totalSize > maxSize) {
+			return MultipartUpload{}, inputErrorf("file size %d bytes is outside the allowed range %d-%d bytes", totalSize, minSize, maxSize)
+		}
+		headers["Content-Type"] = u.restrictedUploadHeaders(objectName)["Content-Type"]
+	}
+
+	partSize, err := multipartPartSize(totalSize, options.PartSize)
+	if err != nil {
+		return MultipartUpload{}, err
+	}
+
+	// Generate unique object name
+	uniqueObjectName, err := u.uploadKey(objectName, options.ContentHash, options.KeyValues, false)
+	if err != nil {
+		return MultipartUpload{}, err
+	}
+	if err := u.authorize(ctx, OperationUpload, bucketName, uniqueObjectName); err != nil {
+		return MultipartUpload{}, err
+	}
+
+	optionHeaders, err := uploadOptionHeaders(UploadOptions{
+		UseOriginalName:   u.keptObjectName(objectName, uniqueObjectName),
+		Metadata:          options.Metadata,
+		DoesNotExist:      options.DoesNotExist,
+		IfGenerationMatch: options.IfGenerationMatch,
+		ContentHash:       options.ContentHash,
+	})
+	if err != nil {
+		return MultipartUpload{}, err
+	}
+	// In single upload mode neither initiation nor completion may replace an existing object
+	optionHeaders = u.applySingleUpload(optionHeaders)
+	for name, value := range optionHeaders {
+		headers[name] = value
+	}
+
+	storageOptions, err := u.storageOptions(bucketName, options.Storage)
+	if err != nil {
+		return MultipartUpload{}, err
+	}
+	for name, value := range storageOptions.headers() {
+		headers[name] = value
+	}
+
+	// CSEK headers are also required on every part upload
+	encryptionKey, err := u.encryptionKey(ctx, options.EncryptionKey, bucketName, uniqueObjectName)
+	if err != nil {
+		return MultipartUpload{}, err
+	}
+	for name, value := range encryptionKey.uploadHeaders() {
+		headers[name] = value
+	}
+
+	expiry := u.defaultExpiry
+	if options.Expiry > 0 {
+		expiry = options.Expiry
+	}
+	expires := time.Now().Add(expiry)
+
+	// Initiate the upload server-side; part URLs cannot be signed without the upload ID
+	initURL, err := u.signMultipartURL(ctx, "POST", bucketName, uniqueObjectName, expires, url.Values{"uploads": {""}}, headers)
+	if err != nil {
+		return MultipartUpload{}, err
+	}
+	status, _, body, err := u.sendSignedRequest(ctx, "POST", initURL, headers, nil)
+	if err != nil {
+		return MultipartUpload{}, fmt.Errorf("failed to initiate multipart upload: %w", err)
+	}
+	if status != http.StatusOK {
+		return MultipartUpload{}, fmt.Errorf("failed to initiate multipart upload: status %d: %s", status, strings.TrimSpace(string(body)))
+	}
+	var result initiateMultipartUploadResult
+	if err := xml.Unmarshal(body, &result); err != nil || result.UploadID == "" {
+		return MultipartUpload{}, fmt.Errorf("failed to parse multipart upload ID from response: %s", strings.TrimSpace(string(body)))
+	}
+
+	upload := MultipartUpload{
+		UploadID:     result.UploadID,
+		Bucket:       bucketName,
+		GeneratedKey: uniqueObjectName,
+		OriginalName: objectName,
+		TotalSize:    totalSize,
+		PartSize:     partSize,
+		ExpiresAt:    expires,
+	}
+
+	// Each part URL is bound to its exact Content-Length, so the declared size is enforced
+	for offset, partNumber := int64(0), 1; offset < totalSize; offset, partNumber = offset+partSize, partNumber+1 {
+		size := min(partSize, totalSize-offset)
+		partHeaders := map[string]string{"Content-Length": strconv.FormatInt(size, 10)}
+		for name, value := range encryptionKey.customerKeyHeaders() {
+			partHeaders[name] = value
+		}
+		query := url.Values{
+			"partNumber": {strconv.Itoa(partNumber)},
+			"uploadId":   {result.UploadID},
+		}
+		partURL, err := u.signMultipartURL(ctx, "PUT", bucketName, uniqueObjectName, expires, query, partHeaders)
+		if err != nil {
+			return MultipartUpload{}, err
+		}
+		upload.Parts = append(upload.Parts, MultipartPart{
+			PartNumber: partNumber,
+			URL:        partURL,
+			Offset:     offset,
+			Size:       size,
+			Headers:    partHeaders,
+		})
+	}
+
+	// GCS checks the precondition again when the parts are assembled
+	if generation, ok := optionHeaders["x-goog-if-generation-match"]; ok {
+		upload.CompleteHeaders = map[string]string{"x-goog-if-generation-match": generation}
+	}
+	uploadIDQuery := url.Values{"uploadId": {result.UploadID}}
+	if upload.CompleteURL, err = u.signMultipartURL(ctx, "POST", bucketName, uniqueObjectName, expires, uploadIDQuery, upload.CompleteHeaders); err != nil {
+		return MultipartUpload{}, err
+	}
+	if upload.AbortURL, err = u.signMultipartURL(ctx, "DELETE", bucketName, uniqueObjectName, expires, uploadIDQuery, nil); err != nil {
+		return MultipartUpload{}, err
+	}
+	return upload, nil
+}
+
+// CompleteMultipartUpload completes a multipart upload server-side from the parts' ETags
+func (u *URLGenerator) CompleteMultipartUpload(ctx context.Context, upload MultipartUpload, parts []CompletedPart) error {
+	body, err := CompleteMultipartUploadBody(parts)
+	if err != nil {
+		return err
+	}
+	status, _, respBody, err := u.sendSignedRequest(ctx, "POST", upload.CompleteURL, upload.CompleteHeaders, body)
+	if err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("failed to complete multipart upload: status %d: %s", status, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+// AbortMultipartUpload aborts a multipart upload server-side and discards uploaded parts
+func (u *URLGenerator) AbortMultipartUpload(ctx context.Context, upload MultipartUpload) error {
+	status, _, respBody, err := u.sendSignedRequest(ctx, "DELETE", upload.AbortURL, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to abort multipart upload: %w", err)
+	}
+	if status != http.StatusNoContent && status != http.StatusOK {
+		return fmt.Errorf("failed to abort multipart upload: status %d: %s", status, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+// CompleteMultipartUploadBody builds the XML body clients POST to CompleteURL
+func CompleteMultipartUploadBody(parts []CompletedPart) ([]byte, error) {
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("at least one part is required to complete a multipart upload")
+	}
+	body, err := xml.Marshal(completeMultipartUpload{Parts: parts})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode multipart completion: %w", err)
+	}
+	return body, nil
+}
+
+// signMultipartURL signs a multipart upload URL with extra query parameters
+func (u *URLGenerator) signMultipartURL(ctx context.Context, method, bucketName, objectName string, expires time.Time, query url.Values, headers map[string]string) (string, error) {
+	opts, err := u.newSignedURLOptions(ctx, method, expires)
+	if err != nil {
+		return "", err
+	}
+	opts.QueryParameters = query
+	applyHeaders(opts, headers)
+
+	signedURL, err := storage.SignedURL(bucketName, objectName, opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate signed multipart %s URL: %w", method, err)
+	}
+	return signedURL, nil
+}
+
+// multipartPartSize returns the part size for totalSize, honoring the XML API limits
+func multipartPartSize(totalSize, requested int64) (int64, error) {
+	partSize := requested
+	if partSize == 0 {
+		partSize = defaultMultipartPartSize
+		// Grow parts so the upload fits in the maximum part count
+		if minForCount := (totalSize + maxMultipartParts - 1) / maxMultipartParts; minForCount > partSize {
+			partSize = minForCount
+		}
+	}
+	if partSize < minMultipartPartSize || partSize > maxMultipartPartSize {
+		return 0, inputErrorf("part size must be between %d and %d bytes, got %d", minMultipartPartSize, maxMultipartPartSize, partSize)
+	}
+	if parts := (totalSize + partSize - 1) / partSize; parts > maxMultipartParts {
+		return 0, inputErrorf("file size %d bytes needs %d parts of %d bytes, more than the maximum of %d", totalSize, parts, partSize, maxMultipartParts)
+	}
+	return partSize, nil
+}
+
+// signedURLHTTPClient returns the client requests to signed URLs are sent with
+func (u *URLGenerator) signedURLHTTPClient() *http.Client {
+	if u.signedURLClient != nil {
+		return u.signedURLClient
+	}
+	return http.DefaultClient
+}
+
+// sendSignedRequest sends a request to a signed URL and returns the status, headers and body
+func (u *URLGenerator) sendSignedRequest(ctx context.Context, method, signedURL string, headers map[string]string, body []byte) (int, http.Header, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, signedURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := u.signedURLHTTPClient().Do(req)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	return resp.StatusCode, resp.Header, respBody, nil
+}
@@ -0,0 +1,135 @@
+package gcsurl_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tropikoearth/gcsurl"
+)
+
+func TestMultipartUploadPreconditions(t *testing.T) {
+	preconditions := make(map[string]string)
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		switch {
+		case r.Method == http.MethodPost && query.Has("uploads"):
+			preconditions["initiate"] = r.Header.Get("x-goog-if-generation-match")
+			w.Write([]byte(`<InitiateMultipartUploadResult><UploadId>upload-1</UploadId></InitiateMultipartUploadResult>`))
+		case r.Method == http.MethodPost && query.Get("uploadId") == "upload-1":
+			preconditions["complete"] = r.Header.Get("x-goog-if-generation-match")
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	}))
+	defer endpoint.Close()
+
+	signer, err := gcsurl.NewTestSigner("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := gcsurl.Config{BucketName: "videos", Signer: signer, Endpoint: endpoint.URL}
+	multiple, err := gcsurl.NewURLGeneratorWithConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.UploadRestrictions = &gcsurl.UploadRestrictions{AllowMultiple: false}
+	single, err := gcsurl.NewURLGeneratorWithConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.NameStrategy = gcsurl.OriginalNames()
+	original, err := gcsurl.NewURLGeneratorWithConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		generator *gcsurl.URLGenerator
+		options   gcsurl.MultipartUploadOptions
+		want      string
+	}{
+		{"single upload mode", single, gcsurl.MultipartUploadOptions{}, "0"},
+		{"does not exist", multiple, gcsurl.MultipartUploadOptions{DoesNotExist: true}, "0"},
+		{"generation match", original, gcsurl.MultipartUploadOptions{IfGenerationMatch: 42}, "42"},
+		{"multiple uploads", multiple, gcsurl.MultipartUploadOptions{}, ""},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clear(preconditions)
+			upload, err := tt.generator.CreateMultipartUploadWithOptions(ctx, "videos", "holiday.mp4", 1024, tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := upload.CompleteHeaders["x-goog-if-generation-match"]; got != tt.want {
+				t.Errorf("CompleteHeaders = %v, want x-goog-if-generation-match %q", upload.CompleteHeaders, tt.want)
+			}
+			if err := tt.generator.CompleteMultipartUpload(ctx, upload, []gcsurl.CompletedPart{{PartNumber: 1, ETag: `"etag"`}}); err != nil {
+				t.Fatal(err)
+			}
+			if preconditions["initiate"] != tt.want || preconditions["complete"] != tt.want {
+				t.Errorf("sent preconditions = %v, want %q on initiate and complete", preconditions, tt.want)
+			}
+		})
+	}
+
+	if _, err := single.CreateMultipartUploadWithOptions(ctx, "videos", "holiday.mp4", 1024, gcsurl.MultipartUploadOptions{DoesNotExist: true, IfGenerationMatch: 1}); err == nil {
+		t.Error("conflicting preconditions: want an error")
+	}
+	if _, err := single.CreateMultipartUploadWithOptions(ctx, "videos", "holiday.mp4", 1024, gcsurl.MultipartUploadOptions{IfGenerationMatch: 42}); !errors.Is(err, gcsurl.ErrInvalidInput) {
+		t.Errorf("generation match on a generated key: error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestMultipartUploadKeys(t *testing.T) {
+	var precondition string
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		precondition = r.Header.Get("x-goog-if-generation-match")
+		w.Write([]byte(`<InitiateMultipartUploadResult><UploadId>upload-1</UploadId></InitiateMultipartUploadResult>`))
+	}))
+	defer endpoint.Close()
+
+	signer, err := gcsurl.NewTestSigner("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	templated, err := gcsurl.NewURLGeneratorWithConfig(gcsurl.Config{BucketName: "videos", Signer: signer, Endpoint: endpoint.URL, KeyTemplate: "{tenant}/{id}{ext}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	upload, err := templated.CreateMultipartUploadWithOptions(ctx, "videos", "holiday.mp4", 1024, gcsurl.MultipartUploadOptions{
+		KeyValues: map[string]string{"tenant": "acme"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(upload.GeneratedKey, "acme/") || !strings.HasSuffix(upload.GeneratedKey, ".mp4") {
+		t.Errorf("GeneratedKey = %q, want acme/<id>.mp4", upload.GeneratedKey)
+	}
+	if _, err := templated.CreateMultipartUpload(ctx, "holiday.mp4", 1024); !errors.Is(err, gcsurl.ErrInvalidInput) {
+		t.Errorf("missing key value: error = %v, want ErrInvalidInput", err)
+	}
+
+	hashed, err := gcsurl.NewURLGeneratorWithConfig(gcsurl.Config{BucketName: "videos", Signer: signer, Endpoint: endpoint.URL, NameStrategy: gcsurl.ContentHashNames()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	upload, err = hashed.CreateMultipartUploadWithOptions(ctx, "videos", "clips/holiday.mp4", 1024, gcsurl.MultipartUploadOptions{ContentHash: "ABCDEF01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upload.GeneratedKey != "clips/abcdef01.mp4" {
+		t.Errorf("GeneratedKey = %q, want clips/abcdef01.mp4", upload.GeneratedKey)
+	}
+	if precondition != "0" || upload.CompleteHeaders["x-goog-if-generation-match"] != "0" {
+		t.Errorf("content-hash upload: sent precondition %q, CompleteHeaders %v, want x-goog-if-generation-match:0", precondition, upload.CompleteHeaders)
+	}
+}
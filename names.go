@@ -0,0 +1,192 @@
+package gcsurl
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// NameInput is what a NameStrategy builds an object key from
+type NameInput struct {
+	ObjectName  string            // Name requested by the caller, e.g. "users/123/report.pdf"
+	ContentHash string            // Hex digest of the content, required by ContentHashNames
+	Time        time.Time         // When the key is generated (UTC)
+	Values      map[string]string // Per-request values for KeyTemplate placeholders
+}
+
+// NameStrategy turns a requested object name into the key the object is stored under
+// Select one with Config.NameStrategy; ShortUUIDNames is used when none is set.
+type NameStrategy interface {
+	GenerateName(input NameInput) (string, error)
+}
+
+// NameStrategyFunc adapts a function to the NameStrategy interface
+type NameStrategyFunc func(input NameInput) (string, error)
+
+// GenerateName calls f(input)
+func (f NameStrategyFunc) GenerateName(input NameInput) (string, error) {
+	return f(input)
+}
+
+// ShortUUIDNames prefixes the file name with 8 random hex characters
+// Input: "documents/file.pdf" -> Output: "documents/a1b2c3d4_file.pdf"
+func ShortUUIDNames() NameStrategy {
+	return NameStrategyFunc(func(input NameInput) (string, error) {
+		id, err := generateShortUUID()
+		if err != nil {
+			return "", err
+		}
+		return prefixFilename(input.ObjectName, id), nil
+	})
+}
+
+// UUIDNames prefixes the file name with a random (version 4) UUID
+// Input: "documents/file.pdf" -> Output: "documents/0b6f8a9e-4c1d-4f57-9a0e-2d3c4b5a6f70_file.pdf"
+func UUIDNames() NameStrategy {
+	return NameStrategyFunc(func(input NameInput) (string, error) {
+		id, err := newUUID(4, time.Time{})
+		if err != nil {
+			return "", err
+		}
+		return prefixFilename(input.ObjectName, id), nil
+	})
+}
+
+// SortableNames prefixes the file name with a time-ordered (version 7) UUID
+// Keys in a directory then list in upload order.
+// Input: "documents/file.pdf" -> Output: "documents/019a0e4c-8f00-7b3e-9c5d-1a2b3c4d5e6f_file.pdf"
+func SortableNames() NameStrategy {
+	return NameStrategyFunc(func(input NameInput) (string, error) {
+		id, err := newUUID(7, input.Time)
+		if err != nil {
+			return "", err
+		}
+		return prefixFilename(input.ObjectName, id), nil
+	})
+}
+
+// ContentHashNames names the file after its content hash, so identical files share a key
+// The caller supplies the hash (UploadOptions.ContentHash); the extension is kept. GCS does not
+// check the hash, so upload URLs are signed to create the key only once.
+// Input: "documents/file.pdf" -> Output: "documents/9f86d081884c7d65...b0f00a08.pdf"
+func ContentHashNames() NameStrategy {
+	return NameStrategyFunc(func(input NameInput) (string, error) {
+		hash := strings.ToLower(input.ContentHash)
+		if hash == "" {
+			return "", inputErrorf("content hash is required by the content hash naming strategy")
+		}
+		if _, err := hex.DecodeString(hash); err != nil {
+			return "", inputErrorf("content hash must be hex encoded: %w", err)
+		}
+		dir, filename := path.Split(input.ObjectName)
+		return dir + hash + path.Ext(filename), nil
+	})
+}
+
+// DatePartitionedNames prefixes the key produced by strategy with the UTC date
+// Input: "documents/file.pdf" -> Output: "2026/10/16/documents/a1b2c3d4_file.pdf"
+func DatePartitionedNames(strategy NameStrategy) NameStrategy {
+	if strategy == nil {
+		strategy = ShortUUIDNames()
+	}
+	return NameStrategyFunc(func(input NameInput) (string, error) {
+		key, err := strategy.GenerateName(input)
+		if err != nil {
+			return "", err
+		}
+		return input.Time.Format("2006/01/02/") + key, nil
+	})
+}
+
+// OriginalNames keeps the requested object name unchanged
+// Uploads to an existing name overwrite it unless a precondition is set.
+func OriginalNames() NameStrategy {
+	return NameStrategyFunc(func(input NameInput) (string, error) {
+		return input.ObjectName, nil
+	})
+}
+
+// generateObjectName builds the object key with the configured naming strategy
+func (u *URLGenerator) generateObjectName(objectName, contentHash string, values map[string]string) (string, error) {
+	strategy := u.nameStrategy
+	if strategy == nil {
+		strategy = ShortUUIDNames()
+	}
+
+	key, err := strategy.GenerateName(NameInput{
+		ObjectName:  objectName,
+		ContentHash: contentHash,
+		Time:        time.Now().UTC(),
+		Values:      values,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to generate unique object name: %w", err)
+	}
+	if key == "" {
+		return "", fmt.Errorf("failed to generate unique object name: naming strategy returned an empty key")
+	}
+	return key, nil
+}
+
+// prefixFilename inserts "id_" before the file name while preserving directory structure
+func prefixFilename(objectName, id string) string {
+	dir, filename := path.Split(objectName)
+	return dir + id + "_" + filename
+}
+
+// generateShortUUID generates a short UUID-like string (8 characters)
+func generateShortUUID() (string, error) {
+	bytes := make([]byte, 4) // 4 bytes = 8 hex characters
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", bytes), nil
+}
+
+// newUUID generates a random (version 4) or time-ordered (version 7) RFC 9562 UUID
+func newUUID(version byte, t time.Time) (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	if version == 7 {
+		// 48-bit big-endian Unix milliseconds
+		var ms [8]byte
+		binary.BigEndian.PutUint64(ms[:], uint64(t.UnixMilli()))
+		copy(b[:6], ms[2:])
+	}
+	b[6] = b[6]&0x0f | version<<4
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// isGeneratedID reports whether s looks like an ID produced by the built-in strategies
+func isGeneratedID(s string) bool {
+	switch len(s) {
+	case 8:
+		return isLowerHex(s)
+	case 36:
+		for _, i := range []int{8, 13, 18, 23} {
+			if s[i] != '-' {
+				return false
+			}
+		}
+		return isLowerHex(strings.ReplaceAll(s, "-", ""))
+	}
+	return false
+}
+
+// isLowerHex reports whether s only contains lowercase hex digits
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+package gcsurl_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/tropikoearth/gcsurl"
+	"github.com/tropikoearth/gcsurl/gcsurltest"
+)
+
+func TestContentHashNames(t *testing.T) {
+	strategy := gcsurl.ContentHashNames()
+	tests := []struct {
+		hash    string
+		want    string
+		wantErr bool
+	}{
+		{"9F86D081884C7D65", "users/123/9f86d081884c7d65.pdf", false},
+		{"", "", true},
+		{"not-hex", "", true},
+	}
+
+	for _, tt := range tests {
+		key, err := strategy.GenerateName(gcsurl.NameInput{ObjectName: "users/123/contract.pdf", ContentHash: tt.hash})
+		if (err != nil) != tt.wantErr || key != tt.want {
+			t.Errorf("GenerateName(%q) = %q, %v, want %q, wantErr %v", tt.hash, key, err, tt.want, tt.wantErr)
+		}
+		if err != nil && !errors.Is(err, gcsurl.ErrInvalidInput) {
+			t.Errorf("GenerateName(%q) error = %v, want ErrInvalidInput", tt.hash, err)
+		}
+	}
+}
+
+func TestContentHashUploadIsCreateOnly(t *testing.T) {
+	server, err := gcsurltest.NewServer(gcsurltest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	config := server.Config("documents")
+	config.NameStrategy = gcsurl.ContentHashNames()
+	generator, err := gcsurl.NewURLGeneratorWithConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	options := gcsurl.UploadOptions{ContentHash: "2c26b46b68ffc68ff99b453c1d304134"}
+	first, err := generator.GenerateSignedUploadURLWithOptions(ctx, "documents", "users/123/contract.pdf", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Headers["x-goog-if-generation-match"] != "0" {
+		t.Errorf("content-hash upload headers = %v, want x-goog-if-generation-match:0", first.Headers)
+	}
+	if status := putUpload(t, first, []byte("%PDF-1.7 contract")); status != http.StatusOK {
+		t.Fatalf("first upload: status %d, want 200", status)
+	}
+
+	// A second upload claiming the same hash cannot replace the stored content
+	second, err := generator.GenerateSignedUploadURLWithOptions(ctx, "documents", "users/123/other.pdf", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.GeneratedKey != first.GeneratedKey {
+		t.Fatalf("keys differ: %s and %s", first.GeneratedKey, second.GeneratedKey)
+	}
+	if status := putUpload(t, second, []byte("%PDF-1.7 forged")); status != http.StatusPreconditionFailed {
+		t.Errorf("second upload: status %d, want 412", status)
+	}
+
+	options.IfGenerationMatch = 1
+	if _, err := generator.GenerateSignedUploadURLWithOptions(ctx, "documents", "users/123/contract.pdf", options); !errors.Is(err, gcsurl.ErrInvalidInput) {
+		t.Errorf("IfGenerationMatch with a content hash: error = %v, want ErrInvalidInput", err)
+	}
+}
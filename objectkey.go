@@ -0,0 +1,131 @@
+package gcsurl
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxObjectNameBytes is the longest object name GCS accepts
+const maxObjectNameBytes = 1024
+
+// acmeChallengePrefix is reserved by GCS for ACME HTTP-01 challenges
+const acmeChallengePrefix = ".well-known/acme-challenge/"
+
+// ValidateObjectName checks an object name against the GCS naming rules and path traversal
+// Names must be 1-1024 bytes of UTF-8 without control characters, use forward slashes only,
+// have no empty, "." or ".." segments and not start with ".well-known/acme-challenge/".
+func ValidateObjectName(name string) error {
+	if name == "" {
+		return inputErrorf("object name cannot be empty")
+	}
+	if len(name) > maxObjectNameBytes {
+		return inputErrorf("object name is %d bytes, longer than the maximum of %d", len(name), maxObjectNameBytes)
+	}
+	if !utf8.ValidString(name) {
+		return inputErrorf("object name %q is not valid UTF-8", name)
+	}
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f {
+			return inputErrorf("object name %q contains control characters", name)
+		}
+	}
+	if strings.Contains(name, "\\") {
+		return inputErrorf("object name %q must use forward slashes", name)
+	}
+	for _, segment := range strings.Split(name, "/") {
+		switch segment {
+		case "":
+			return inputErrorf("object name %q must not contain empty path segments", name)
+		case ".", "..":
+			return inputErrorf("object name %q must not contain . or .. path segments", name)
+		}
+	}
+	if strings.HasPrefix(name, acmeChallengePrefix) {
+		return inputErrorf("object name %q uses the reserved %s prefix", name, acmeChallengePrefix)
+	}
+	return nil
+}
+
+// normalizeRootPrefix validates a root prefix and gives it a trailing slash
+func normalizeRootPrefix(prefix string) (string, error) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" {
+		return "", nil
+	}
+	if err := ValidateObjectName(prefix); err != nil {
+		return "", fmt.Errorf("invalid root prefix: %w", err)
+	}
+	return prefix + "/", nil
+}
+
+// uploadKey validates a requested object name and returns the key it is stored under
+// Unless keepName is set, the file name is sanitized and the key comes from the naming strategy.
+// The key always lives under the root prefix.
+func (u *URLGenerator) uploadKey(objectName, contentHash string, values map[string]string, keepName bool) (string, error) {
+	if !keepName {
+		objectName = u.sanitizeObjectName(objectName)
+	}
+	if err := ValidateObjectName(objectName); err != nil {
+		return "", err
+	}
+
+	key := objectName
+	if !keepName {
+		var err error
+		if key, err = u.generateObjectName(objectName, contentHash, values); err != nil {
+			return "", err
+		}
+	}
+
+	key = u.rootPrefix + key
+	if err := ValidateObjectName(key); err != nil {
+		return "", fmt.Errorf("generated object key is invalid: %w", err)
+	}
+	return key, u.checkRootPrefix(key)
+}
+
+// keptObjectName reports whether key is the requested object name, as with OriginalNames
+// Only such keys can take IfGenerationMatch, since a generated key has no earlier generation.
+func (u *URLGenerator) keptObjectName(objectName, key string) bool {
+	return key == u.rootPrefix+u.sanitizeObjectName(objectName)
+}
+
+// checkObjectKey checks the key of an existing object and that it is inside the root prefix
+// Existing objects may carry any name GCS accepts, such as "a//b" or a trailing slash, so only
+// the GCS limits are enforced here; the keys the generator creates are held to ValidateObjectName.
+// Under a root prefix . and .. segments are rejected as well, since proxies and clients that
+// normalize paths could resolve "prefix/../other" outside the prefix.
+func (u *URLGenerator) checkObjectKey(key string) error {
+	if key == "" {
+		return inputErrorf("object name cannot be empty")
+	}
+	if len(key) > maxObjectNameBytes {
+		return inputErrorf("object name is %d bytes, longer than the maximum of %d", len(key), maxObjectNameBytes)
+	}
+	if !utf8.ValidString(key) {
+		return inputErrorf("object name %q is not valid UTF-8", key)
+	}
+	if u.rootPrefix != "" && containsDotSegment(key) {
+		return inputErrorf("object name %q cannot contain . or .. segments under the root prefix %q", key, u.rootPrefix)
+	}
+	return u.checkRootPrefix(key)
+}
+
+// checkRootPrefix checks that a key is inside the root prefix
+func (u *URLGenerator) checkRootPrefix(key string) error {
+	if !strings.HasPrefix(key, u.rootPrefix) {
+		return inputErrorf("object name %q is outside the root prefix %q", key, u.rootPrefix)
+	}
+	return nil
+}
+
+// containsDotSegment reports whether a key has a . or .. path segment
+func containsDotSegment(text string) bool {
+	for _, segment := range strings.Split(text, "/") {
+		if segment == "." || segment == ".." {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package gcsurl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateObjectName(t *testing.T) {
+	tests := []struct {
+		name    string
+		object  string
+		wantErr bool
+	}{
+		{"simple", "report.pdf", false},
+		{"nested", "users/123/report.pdf", false},
+		{"unicode", "users/123/résumé 履歴書.pdf", false},
+		{"dots in file name", "archive.tar.gz", false},
+		{"leading dot file", "users/.profile", false},
+		{"maximum length", strings.Repeat("a", 1024), false},
+		{"empty", "", true},
+		{"too long", strings.Repeat("a", 1025), true},
+		{"invalid UTF-8", "report\xff.pdf", true},
+		{"newline", "report\n.pdf", true},
+		{"carriage return", "report\r.pdf", true},
+		{"delete character", "report\x7f.pdf", true},
+		{"backslash", `users\123\report.pdf`, true},
+		{"empty segment", "users//report.pdf", true},
+		{"leading slash", "/report.pdf", true},
+		{"trailing slash", "users/", true},
+		{"dot segment", "users/./report.pdf", true},
+		{"parent segment", "../other-tenant/report.pdf", true},
+		{"only parent", "..", true},
+		{"acme challenge", ".well-known/acme-challenge/token", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateObjectName(tt.object)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateObjectName(%q) error = %v, wantErr %v", tt.object, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidInput) {
+				t.Errorf("ValidateObjectName(%q) error = %v, want ErrInvalidInput", tt.object, err)
+			}
+		})
+	}
+}
+
+func TestCheckObjectKey(t *testing.T) {
+	generator := &URLGenerator{rootPrefix: "tenants/acme/"}
+	tests := []struct {
+		name    string
+		key     string
+		wantErr bool
+	}{
+		{"inside prefix", "tenants/acme/report.pdf", false},
+		{"empty segment", "tenants/acme/a//b", false},
+		{"trailing slash", "tenants/acme/folder/", false},
+		{"control character", "tenants/acme/a\tb", false},
+		{"backslash", `tenants/acme/a\b`, false},
+		{"acme challenge below prefix", "tenants/acme/.well-known/acme-challenge/x", false},
+		{"empty", "", true},
+		{"too long", "tenants/acme/" + strings.Repeat("a", 1024), true},
+		{"invalid UTF-8", "tenants/acme/\xff", true},
+		{"outside prefix", "tenants/other/report.pdf", true},
+		{"prefix without slash", "tenants/acme", true},
+		{"dot segments", "tenants/acme/./a/../b", true},
+		{"escaping dot segments", "tenants/acme/../other/report.pdf", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := generator.checkObjectKey(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkObjectKey(%q) error = %v, wantErr %v", tt.key, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidInput) {
+				t.Errorf("checkObjectKey(%q) error = %v, want ErrInvalidInput", tt.key, err)
+			}
+		})
+	}
+
+	// Without a root prefix there is nothing to escape, so existing keys keep any name GCS accepts
+	if err := (&URLGenerator{}).checkObjectKey("./a/../b"); err != nil {
+		t.Errorf("checkObjectKey without root prefix: error = %v, want nil", err)
+	}
+}
+
+func TestUploadKeyRootPrefix(t *testing.T) {
+	generator := &URLGenerator{rootPrefix: "tenants/acme/"}
+	tests := []struct {
+		object  string
+		want    string
+		wantErr bool
+	}{
+		{"users/123/report.pdf", "tenants/acme/users/123/report.pdf", false},
+		{"../other/report.pdf", "", true},
+		{"users//report.pdf", "", true},
+		{"users/", "", true},
+	}
+
+	for _, tt := range tests {
+		key, err := generator.uploadKey(tt.object, "", nil, true)
+		if (err != nil) != tt.wantErr || key != tt.want {
+			t.Errorf("uploadKey(%q) = %q, %v, want %q, wantErr %v", tt.object, key, err, tt.want, tt.wantErr)
+		}
+	}
+}
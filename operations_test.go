@@ -0,0 +1,107 @@
+package gcsurl_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tropikoearth/gcsurl"
+)
+
+func TestAuthorize(t *testing.T) {
+	signer, err := gcsurl.NewTestSigner("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errDenied := errors.New("denied")
+	type check struct {
+		op     gcsurl.Operation
+		object string
+	}
+	var checks []check
+	generator, err := gcsurl.NewURLGeneratorWithConfig(gcsurl.Config{
+		BucketName: "documents",
+		Signer:     signer,
+		RootPrefix: "tenants/acme/",
+		Authorize: func(ctx context.Context, op gcsurl.Operation, bucketName, objectName string) error {
+			checks = append(checks, check{op, objectName})
+			if strings.Contains(objectName, "private/") {
+				return errDenied
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		op   gcsurl.Operation
+		sign func(objectName string) error
+	}{
+		{"upload", gcsurl.OperationUpload, func(objectName string) error {
+			_, err := generator.GenerateSignedUploadURL(ctx, objectName)
+			return err
+		}},
+		{"upload with exact name", gcsurl.OperationUpload, func(objectName string) error {
+			_, err := generator.GenerateSignedUploadURLWithExpiry(ctx, "documents", objectName, time.Minute)
+			return err
+		}},
+		{"resumable upload", gcsurl.OperationUpload, func(objectName string) error {
+			_, err := generator.GenerateSignedResumableUploadURL(ctx, objectName)
+			return err
+		}},
+		{"POST policy", gcsurl.OperationUpload, func(objectName string) error {
+			_, err := generator.GenerateSignedPostPolicy(ctx, objectName)
+			return err
+		}},
+		{"download", gcsurl.OperationDownload, func(objectName string) error {
+			_, err := generator.GenerateSignedDownload(ctx, "tenants/acme/"+objectName)
+			return err
+		}},
+		{"delete", gcsurl.OperationDelete, func(objectName string) error {
+			_, err := generator.GenerateSignedDeleteURL(ctx, "tenants/acme/"+objectName)
+			return err
+		}},
+		{"head", gcsurl.OperationHead, func(objectName string) error {
+			_, err := generator.GenerateSignedHeadURL(ctx, "tenants/acme/"+objectName)
+			return err
+		}},
+		// Denied before any request is sent to GCS
+		{"multipart upload", gcsurl.OperationUpload, func(objectName string) error {
+			_, err := generator.CreateMultipartUpload(ctx, objectName, 1024)
+			return err
+		}},
+		{"slot upload", gcsurl.OperationUpload, func(objectName string) error {
+			_, err := generator.GenerateSignedSlotUploadURL(ctx, objectName)
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks = nil
+			err := tt.sign("private/report.pdf")
+			if !errors.Is(err, errDenied) || !strings.Contains(err.Error(), "not authorized to "+string(tt.op)) {
+				t.Errorf("denied: error = %v, want the hook's error", err)
+			}
+			if len(checks) != 1 || checks[0].op != tt.op || !strings.HasPrefix(checks[0].object, "tenants/acme/private/") {
+				t.Errorf("hook calls = %v, want one %s check of a key under tenants/acme/private/", checks, tt.op)
+			}
+		})
+	}
+
+	// Uploads are authorized with the key the URL is signed for
+	checks = nil
+	upload, err := generator.GenerateSignedUploadURL(ctx, "public/report.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) != 1 || checks[0] != (check{gcsurl.OperationUpload, upload.GeneratedKey}) {
+		t.Errorf("hook calls = %v, want one upload check of %q", checks, upload.GeneratedKey)
+	}
+}
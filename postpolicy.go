@@ -0,0 +1,240 @@
+package gcsurl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// formFilenamePlaceholder is replaced by GCS with the name of the uploaded file
+const formFilenamePlaceholder = "${filename}"
+
+// DocumentPostPolicy contains a signed V4 POST policy for browser form uploads
+type DocumentPostPolicy struct {
+	URL          string            `json:"url"`          // Form action URL
+	Fields       map[string]string `json:"fields"`       // Form fields to send before the file field
+	ExpiresAt    time.Time         `json:"expiresAt"`    // When the policy expires
+	GeneratedKey string            `json:"generatedKey"` // Object key (may contain ${filename})
+	OriginalName string            `json:"originalName"` // Original file name provided by user
+}
+
+// PostPolicyOptions customizes a signed POST policy
+type PostPolicyOptions struct {
+	// Expiry overrides the default expiry when set
+	Expiry time.Duration
+	// UseFormFilename keeps the unique prefix but lets GCS fill in the name of the submitted file
+	// The policy then only requires the key to start with the unique prefix.
+	UseFormFilename bool
+	// ContentTypePrefix accepts any content-type starting with this value (e.g. "image/")
+	// instead of the exact type detected from the file extension. The client must then
+	// add its own content-type form field.
+	ContentTypePrefix string
+	// SuccessRedirectURL is where the browser is redirected after a successful upload
+	SuccessRedirectURL string
+	// SuccessStatusCode is the status GCS returns after a successful upload (200, 201 or 204)
+	SuccessStatusCode int
+	// Metadata is stored as custom metadata; the policy requires the exact x-goog-meta-* fields
+	Metadata map[string]string
+	// DoesNotExist rejects the upload if the object already exists (x-goog-if-generation-match:0)
+	DoesNotExist bool
+	// IfGenerationMatch only lets the upload replace this generation of the object
+	// It requires a naming strategy that keeps the object name, such as OriginalNames.
+	IfGenerationMatch int64
+	// ContentHash is the hex digest of the file, used by the ContentHashNames strategy
+	// As in UploadOptions, the policy requires x-goog-if-generation-match:0.
+	ContentHash string
+	// KeyValues fills the custom placeholders of Config.KeyTemplate (e.g. {"tenant": "acme"})
+	KeyValues map[string]string
+	// Storage sets the caching, encoding and ACL fields of the form, overriding Config.BucketStorageOptions
+	// POST policies cannot set a storage class or content language.
+	Storage StorageOptions
+}
+
+// GenerateSignedPostPolicy generates a signed POST policy for uploading to the default bucket
+// Upload restrictions and unique naming are applied exactly as in GenerateSignedUploadURL,
+// but enforced server-side by the policy conditions (content-length-range, key, content-type).
+func (u *URLGenerator) GenerateSignedPostPolicy(ctx context.Context, objectName string) (DocumentPostPolicy, error) {
+	return u.GenerateSignedPostPolicyWithOptions(ctx, u.bucketName, objectName, PostPolicyOptions{})
+}
+
+// GenerateSignedPostPolicyWithOptions generates a signed POST policy for a specific bucket with options
+func (u *URLGenerator) GenerateSignedPostPolicyWithOptions(ctx context.Context, bucketName, objectName string, options PostPolicyOptions) (DocumentPostPolicy, error) {
+	if u.hasRestrictions() {
+		if err := u.ValidateUpload(objectName); err != nil {
+			return DocumentPostPolicy{}, err
+		}
+	}
+	if options.SuccessStatusCode != 0 && options.SuccessStatusCode != 200 && options.SuccessStatusCode != 201 && options.SuccessStatusCode != 204 {
+		return DocumentPostPolicy{}, inputErrorf("success status code must be 200, 201 or 204, got %d", options.SuccessStatusCode)
+	}
+
+	// Generate unique object name
+	uniqueObjectName, err := u.uploadKey(objectName, options.ContentHash, options.KeyValues, false)
+	if err != nil {
+		return DocumentPostPolicy{}, err
+	}
+	if err := u.authorize(ctx, OperationUpload, bucketName, uniqueObjectName); err != nil {
+		return DocumentPostPolicy{}, err
+	}
+
+	// Form uploads cannot carry signed encryption headers
+	if encryptionKey, err := u.encryptionKey(ctx, nil, bucketName, uniqueObjectName); err != nil {
+		return DocumentPostPolicy{}, err
+	} else if encryptionKey != nil {
+		return DocumentPostPolicy{}, fmt.Errorf("encryption keys are not supported for POST policy uploads")
+	}
+
+	var conditions []storage.PostPolicyV4Condition
+	key := uniqueObjectName
+	if options.UseFormFilename {
+		// Keep "dir/uuid_" and let the browser supply the rest
+		prefix, ok := strings.CutSuffix(uniqueObjectName, path.Base(u.sanitizeObjectName(objectName)))
+		if !ok {
+			return DocumentPostPolicy{}, fmt.Errorf("UseFormFilename requires a naming strategy that keeps the file name")
+		}
+		key = prefix + formFilenamePlaceholder
+		conditions = append(conditions, storage.ConditionStartsWith("$key", prefix))
+	}
+
+	if minSize, maxSize, ok := u.uploadRestrictions.contentLengthRange(); ok {
+		conditions = append(conditions, storage.ConditionContentLengthRange(uint64(minSize), uint64(maxSize)))
+	}
+
+	optionHeaders, err := uploadOptionHeaders(UploadOptions{
+		UseOriginalName:   u.keptObjectName(objectName, uniqueObjectName),
+		Metadata:          options.Metadata,
+		DoesNotExist:      options.DoesNotExist,
+		IfGenerationMatch: options.IfGenerationMatch,
+		ContentHash:       options.ContentHash,
+	})
+	if err != nil {
+		return DocumentPostPolicy{}, err
+	}
+	// In single upload mode the form cannot replace an existing object
+	metadata := u.applySingleUpload(optionHeaders)
+	generation, hasPrecondition := metadata["x-goog-if-generation-match"]
+	if hasPrecondition {
+		delete(metadata, "x-goog-if-generation-match")
+		conditions = append(conditions, exactMatch("x-goog-if-generation-match", generation))
+	}
+
+	storageOptions, err := u.storageOptions(bucketName, options.Storage)
+	if err != nil {
+		return DocumentPostPolicy{}, err
+	}
+	if storageOptions.StorageClass != "" || storageOptions.ContentLanguage != "" {
+		return DocumentPostPolicy{}, inputErrorf("storage class and content language are not supported for POST policy uploads")
+	}
+
+	fields := &storage.PolicyV4Fields{
+		ACL:                    storageOptions.ACL,
+		CacheControl:           storageOptions.CacheControl,
+		ContentEncoding:        storageOptions.ContentEncoding,
+		RedirectToURLOnSuccess: options.SuccessRedirectURL,
+		StatusCodeOnSuccess:    options.SuccessStatusCode,
+		Metadata:               metadata,
+	}
+	if options.ContentTypePrefix != "" {
+		conditions = append(conditions, storage.ConditionStartsWith("$content-type", options.ContentTypePrefix))
+	} else {
+		fields.ContentType = "application/octet-stream"
+		if ext := strings.ToLower(path.Ext(objectName)); ext != "" {
+			fields.ContentType = getContentTypeFromExtension(ext)
+		}
+	}
+
+	expiry := u.defaultExpiry
+	if options.Expiry > 0 {
+		expiry = options.Expiry
+	}
+	expires := time.Now().Add(expiry)
+
+	opts, err := u.newPostPolicyOptions(ctx, expires)
+	if err != nil {
+		return DocumentPostPolicy{}, err
+	}
+	opts.Fields = fields
+	opts.Conditions = conditions
+
+	policy, err := storage.GenerateSignedPostPolicyV4(bucketName, key, opts)
+	if err != nil {
+		return DocumentPostPolicy{}, fmt.Errorf("failed to generate signed post policy: %w", err)
+	}
+	if hasPrecondition {
+		policy.Fields["x-goog-if-generation-match"] = generation
+	}
+	return DocumentPostPolicy{
+		URL:          policy.URL,
+		Fields:       policy.Fields,
+		ExpiresAt:    expires,
+		GeneratedKey: key,
+		OriginalName: objectName,
+	}, nil
+}
+
+// exactMatchCondition requires a form field to equal a value
+// The storage package only builds exact matches for its own fields; the embedded
+// condition provides the unexported part of the interface.
+type exactMatchCondition struct {
+	storage.PostPolicyV4Condition
+	field, value string
+}
+
+// exactMatch returns an ["eq", "$field", value] policy condition
+func exactMatch(field, value string) storage.PostPolicyV4Condition {
+	return exactMatchCondition{storage.ConditionStartsWith("$"+field, value), field, value}
+}
+
+// MarshalJSON encodes the condition for the policy document
+func (c exactMatchCondition) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]string{"eq", "$" + c.field, c.value})
+}
+
+// newPostPolicyOptions returns V4 post policy options with the configured signer applied
+func (u *URLGenerator) newPostPolicyOptions(ctx context.Context, expires time.Time) (*storage.PostPolicyV4Options, error) {
+	if u.signer == nil {
+		return nil, fmt.Errorf("service account not loaded - configure GCS_SERVICE_ACCOUNT_JSON or GOOGLE_APPLICATION_CREDENTIALS")
+	}
+
+	accessID, signBytes, err := signingFunc(ctx, u.signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve signing credentials: %w", err)
+	}
+	opts := &storage.PostPolicyV4Options{
+		GoogleAccessID: accessID,
+		SignRawBytes:   signBytes,
+		Expires:        expires,
+	}
+	if u.endpoint != nil {
+		opts.Hostname = u.endpoint.Host
+		opts.Insecure = u.endpoint.Scheme == "http"
+	}
+	return opts, nil
+}
+
+// HTMLForm renders a multipart/form-data upload form for the policy
+// Hidden fields come first and the file input last, as GCS requires.
+func (p DocumentPostPolicy) HTMLForm() string {
+	names := make([]string, 0, len(p.Fields))
+	for name := range p.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<form action=\"%s\" method=\"POST\" enctype=\"multipart/form-data\">\n", html.EscapeString(p.URL))
+	for _, name := range names {
+		fmt.Fprintf(&b, "  <input type=\"hidden\" name=\"%s\" value=\"%s\">\n", html.EscapeString(name), html.EscapeString(p.Fields[name]))
+	}
+	b.WriteString("  <input type=\"file\" name=\"file\">\n")
+	b.WriteString("  <input type=\"submit\" value=\"Upload\">\n")
+	b.WriteString("</form>\n")
+	return b.String()
+}
@@ -0,0 +1,190 @@
+package gcsurl_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/tropikoearth/gcsurl"
+	"github.com/tropikoearth/gcsurl/gcsurltest"
+)
+
+// postForm submits a POST policy form upload and returns the status
+func postForm(t *testing.T, policy gcsurl.DocumentPostPolicy, fields map[string]string, data []byte) int {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range policy.Fields {
+		form.WriteField(name, value)
+	}
+	for name, value := range fields {
+		form.WriteField(name, value)
+	}
+	file, err := form.CreateFormFile("file", "upload.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write(data)
+	form.Close()
+
+	resp, err := http.Post(policy.URL, form.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestPostPolicyPreconditions(t *testing.T) {
+	server, err := gcsurltest.NewServer(gcsurltest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	config := server.Config("documents")
+	config.NameStrategy = gcsurl.OriginalNames()
+	generator, err := gcsurl.NewURLGeneratorWithConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.UploadRestrictions = &gcsurl.UploadRestrictions{AllowMultiple: false}
+	single, err := gcsurl.NewURLGeneratorWithConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	create, err := generator.GenerateSignedPostPolicyWithOptions(ctx, "documents", "reports/q1.pdf", gcsurl.PostPolicyOptions{DoesNotExist: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if create.Fields["x-goog-if-generation-match"] != "0" {
+		t.Fatalf("Fields = %v, want x-goog-if-generation-match:0", create.Fields)
+	}
+	if status := postForm(t, create, nil, []byte("%PDF-1.7 first")); status != http.StatusNoContent {
+		t.Fatalf("first upload: status %d, want 204", status)
+	}
+	if status := postForm(t, create, nil, []byte("%PDF-1.7 second")); status != http.StatusPreconditionFailed {
+		t.Errorf("replayed create-only form: status %d, want 412", status)
+	}
+
+	// The precondition is part of the policy, so the client cannot change it
+	if status := postForm(t, create, map[string]string{"x-goog-if-generation-match": ""}, []byte("%PDF-1.7 forged")); status != http.StatusForbidden {
+		t.Errorf("altered precondition: status %d, want 403", status)
+	}
+
+	stored, _ := server.Object("documents", "reports/q1.pdf")
+	replace, err := generator.GenerateSignedPostPolicyWithOptions(ctx, "documents", "reports/q1.pdf", gcsurl.PostPolicyOptions{IfGenerationMatch: stored.Generation})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := postForm(t, replace, nil, []byte("%PDF-1.7 revised")); status != http.StatusNoContent {
+		t.Fatalf("generation-matched upload: status %d, want 204", status)
+	}
+	if status := postForm(t, replace, nil, []byte("%PDF-1.7 stale")); status != http.StatusPreconditionFailed {
+		t.Errorf("stale generation: status %d, want 412", status)
+	}
+
+	policy, err := single.GenerateSignedPostPolicy(ctx, "reports/q2.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.Fields["x-goog-if-generation-match"] != "0" {
+		t.Errorf("single upload mode fields = %v, want x-goog-if-generation-match:0", policy.Fields)
+	}
+	if policy, err := generator.GenerateSignedPostPolicy(ctx, "reports/q3.pdf"); err != nil || policy.Fields["x-goog-if-generation-match"] != "" {
+		t.Errorf("multiple upload mode fields = %v, %v, want no precondition", policy.Fields, err)
+	}
+
+	_, err = generator.GenerateSignedPostPolicyWithOptions(ctx, "documents", "reports/q1.pdf", gcsurl.PostPolicyOptions{DoesNotExist: true, IfGenerationMatch: 1})
+	if !errors.Is(err, gcsurl.ErrInvalidInput) || !strings.Contains(err.Error(), "cannot be combined") {
+		t.Errorf("conflicting preconditions: error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestPostPolicyKeys(t *testing.T) {
+	server, err := gcsurltest.NewServer(gcsurltest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	ctx := context.Background()
+
+	config := server.Config("documents")
+	config.KeyTemplate = "{tenant}/{id}{ext}"
+	templated, err := gcsurl.NewURLGeneratorWithConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err := templated.GenerateSignedPostPolicyWithOptions(ctx, "documents", "q1.pdf", gcsurl.PostPolicyOptions{
+		KeyValues: map[string]string{"tenant": "acme"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(policy.GeneratedKey, "acme/") || policy.Fields["key"] != policy.GeneratedKey {
+		t.Fatalf("GeneratedKey = %q, key field = %q, want acme/<id>.pdf", policy.GeneratedKey, policy.Fields["key"])
+	}
+	if status := postForm(t, policy, nil, []byte("%PDF-1.7 templated")); status != http.StatusNoContent {
+		t.Fatalf("templated upload: status %d, want 204", status)
+	}
+	if _, ok := server.Object("documents", policy.GeneratedKey); !ok {
+		t.Errorf("object %q was not stored", policy.GeneratedKey)
+	}
+
+	config = server.Config("documents")
+	config.NameStrategy = gcsurl.ContentHashNames()
+	hashed, err := gcsurl.NewURLGeneratorWithConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err = hashed.GenerateSignedPostPolicyWithOptions(ctx, "documents", "reports/q1.pdf", gcsurl.PostPolicyOptions{ContentHash: "abcdef01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.GeneratedKey != "reports/abcdef01.pdf" || policy.Fields["x-goog-if-generation-match"] != "0" {
+		t.Fatalf("GeneratedKey = %q, Fields = %v, want reports/abcdef01.pdf with x-goog-if-generation-match:0", policy.GeneratedKey, policy.Fields)
+	}
+	if status := postForm(t, policy, nil, []byte("%PDF-1.7 hashed")); status != http.StatusNoContent {
+		t.Fatalf("content-hash upload: status %d, want 204", status)
+	}
+	if status := postForm(t, policy, nil, []byte("%PDF-1.7 hashed")); status != http.StatusPreconditionFailed {
+		t.Errorf("repeated content-hash upload: status %d, want 412", status)
+	}
+}
+
+func TestPostPolicyInvalidOptions(t *testing.T) {
+	server, err := gcsurltest.NewServer(gcsurltest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	generator, err := gcsurl.NewURLGeneratorWithConfig(server.Config("documents"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		options gcsurl.PostPolicyOptions
+	}{
+		{"success status code", gcsurl.PostPolicyOptions{SuccessStatusCode: 302}},
+		{"storage class", gcsurl.PostPolicyOptions{Storage: gcsurl.StorageOptions{StorageClass: "NEARLINE"}}},
+		{"content language", gcsurl.PostPolicyOptions{Storage: gcsurl.StorageOptions{ContentLanguage: "de"}}},
+		{"generation match on a generated key", gcsurl.PostPolicyOptions{IfGenerationMatch: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := generator.GenerateSignedPostPolicyWithOptions(context.Background(), "documents", "reports/q1.pdf", tt.options)
+			if !errors.Is(err, gcsurl.ErrInvalidInput) {
+				t.Errorf("error = %v, want ErrInvalidInput", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package gcsurl
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// resumableSessionLifetime is how long GCS keeps a resumable upload session open
+const resumableSessionLifetime = 7 * 24 * time.Hour
+
+// ResumableUploadSession contains an initiated resumable upload session
+// Clients upload the file with one or more PUT requests to SessionURI using Content-Range,
+// and can query the session to resume after a network drop.
+type ResumableUploadSession struct {
+	SessionURI   string            `json:"sessionUri"`             // Session URI to PUT the file to
+	ExpiresAt    time.Time         `json:"expiresAt"`              // When the session expires (one week after initiation)
+	GeneratedKey string            `json:"generatedKey"`           // Unique file path for storage
+	OriginalName string            `json:"originalName"`           // Original file name provided by user
+	Headers      map[string]string `json:"headers,omitempty"`      // Headers every PUT to the session must send (CSEK keys)
+	FinalHeaders map[string]string `json:"finalHeaders,omitempty"` // Headers the PUT uploading the last byte must also send (x-goog-hash)
+}
+
+// GenerateSignedResumableUploadURL generates a signed POST URL that starts a resumable upload
+// The client must POST to it with the returned headers (including x-goog-resumable:start) and
+// then upload to the session URI from the Location response header.
+// Restrictions and unique naming are applied exactly as in GenerateSignedUploadURL.
+func (u *URLGenerator) GenerateSignedResumableUploadURL(ctx context.Context, objectName string) (DocumentUpload, error) {
+	return u.GenerateSignedResumableUploadURLWithBucket(ctx, u.bucketName, objectName)
+}
+
+// GenerateSignedResumableUploadURLWithBucket generates a signed resumable upload start URL for a specific bucket
+func (u *URLGenerator) GenerateSignedResumableUploadURLWithBucket(ctx context.Context, bucketName, objectName string) (DocumentUpload, error) {
+	return u.GenerateSignedResumableUploadURLWithOptions(ctx, bucketName, objectName, UploadOptions{})
+}
+
+// GenerateSignedResumableUploadURLWithOptions generates a signed resumable upload start URL with options
+// Naming, metadata, preconditions, storage options and encryption keys are signed into the start
+// request as in GenerateSignedUploadURLWithOptions. GCS checks hashes per request, so a Checksum
+// is not signed: it is returned in DocumentUpload.Checksum for the client to send as x-goog-hash
+// with the request that uploads the last byte.
+func (u *URLGenerator) GenerateSignedResumableUploadURLWithOptions(ctx context.Context, bucketName, objectName string, options UploadOptions) (DocumentUpload, error) {
+	optionHeaders, err := uploadOptionHeaders(options)
+	if err != nil {
+		return DocumentUpload{}, err
+	}
+
+	// Generate unique object name
+	uniqueObjectName, err := u.uploadKey(objectName, options.ContentHash, options.KeyValues, options.UseOriginalName)
+	if err != nil {
+		return DocumentUpload{}, err
+	}
+	if err := u.authorize(ctx, OperationUpload, bucketName, uniqueObjectName); err != nil {
+		return DocumentUpload{}, err
+	}
+
+	headers := map[string]string{"Content-Type": "application/octet-stream"}
+	if u.hasRestrictions() {
+		if err := u.ValidateUpload(objectName); err != nil {
+			return DocumentUpload{}, err
+		}
+		headers = u.restrictedUploadHeaders(objectName)
+	}
+	headers["x-goog-resumable"] = "start"
+	// In single upload mode the session cannot finalize once the object exists
+	for name, value := range u.applySingleUpload(optionHeaders) {
+		headers[name] = value
+	}
+
+	storageOptions, err := u.storageOptions(bucketName, options.Storage)
+	if err != nil {
+		return DocumentUpload{}, err
+	}
+	for name, value := range storageOptions.headers() {
+		headers[name] = value
+	}
+
+	// CSEK headers must also be sent with every PUT to the session
+	encryptionKey, err := u.encryptionKey(ctx, options.EncryptionKey, bucketName, uniqueObjectName)
+	if err != nil {
+		return DocumentUpload{}, err
+	}
+	for name, value := range encryptionKey.uploadHeaders() {
+		headers[name] = value
+	}
+
+	expiry := u.defaultExpiry
+	if options.Expiry > 0 {
+		expiry = options.Expiry
+	}
+	expires := time.Now().Add(expiry)
+	opts, err := u.newSignedURLOptions(ctx, "POST", expires)
+	if err != nil {
+		return DocumentUpload{}, err
+	}
+	applyHeaders(opts, headers)
+
+	signedURL, err := storage.SignedURL(bucketName, uniqueObjectName, opts)
+	if err != nil {
+		return DocumentUpload{}, fmt.Errorf("failed to generate signed resumable upload URL: %w", err)
+	}
+
+	upload := DocumentUpload{
+		UploadURL:    signedURL,
+		ExpiresAt:    expires,
+		GeneratedKey: uniqueObjectName,
+		OriginalName: objectName,
+		Headers:      headers,
+	}
+	if !options.Checksum.isZero() {
+		checksum := options.Checksum
+		upload.Checksum = &checksum
+	}
+	return upload, nil
+}
+
+// customerKeySessionHeaders picks the CSEK headers out of the session start headers
+func customerKeySessionHeaders(headers map[string]string) map[string]string {
+	var session map[string]string
+	for _, name := range []string{"x-goog-encryption-algorithm", "x-goog-encryption-key", "x-goog-encryption-key-sha256"} {
+		if value, ok := headers[name]; ok {
+			if session == nil {
+				session = make(map[string]string)
+			}
+			session[name] = value
+		}
+	}
+	return session
+}
+
+// StartResumableUpload starts a resumable upload session server-side and returns its session URI
+// Hand the session URI to the client; it does not need credentials or signed headers to use it.
+func (u *URLGenerator) StartResumableUpload(ctx context.Context, objectName string) (ResumableUploadSession, error) {
+	return u.StartResumableUploadWithOrigin(ctx, objectName, "")
+}
+
+// StartResumableUploadWithOrigin starts a resumable upload session for a browser client
+// GCS only allows cross-origin uploads to the session URI from the origin given at initiation.
+func (u *URLGenerator) StartResumableUploadWithOrigin(ctx context.Context, objectName, origin string) (ResumableUploadSession, error) {
+	return u.StartResumableUploadWithOptions(ctx, u.bucketName, objectName, origin, UploadOptions{})
+}
+
+// StartResumableUploadWithOptions starts a resumable upload session in a specific bucket with options
+// origin may be empty for clients that are not browsers. Options apply as in
+// GenerateSignedResumableUploadURLWithOptions.
+func (u *URLGenerator) StartResumableUploadWithOptions(ctx context.Context, bucketName, objectName, origin string, options UploadOptions) (ResumableUploadSession, error) {
+	upload, err := u.GenerateSignedResumableUploadURLWithOptions(ctx, bucketName, objectName, options)
+	if err != nil {
+		return ResumableUploadSession{}, err
+	}
+
+	headers := maps.Clone(upload.Headers)
+	if origin != "" {
+		headers["Origin"] = origin
+	}
+	status, respHeaders, body, err := u.sendSignedRequest(ctx, http.MethodPost, upload.UploadURL, headers, nil)
+	if err != nil {
+		return ResumableUploadSession{}, fmt.Errorf("failed to start resumable upload: %w", err)
+	}
+	if status != http.StatusCreated {
+		return ResumableUploadSession{}, fmt.Errorf("failed to start resumable upload: status %d: %s", status, strings.TrimSpace(string(body)))
+	}
+	sessionURI := respHeaders.Get("Location")
+	if sessionURI == "" {
+		return ResumableUploadSession{}, fmt.Errorf("failed to start resumable upload: response has no Location header")
+	}
+
+	session := ResumableUploadSession{
+		SessionURI:   sessionURI,
+		ExpiresAt:    time.Now().Add(resumableSessionLifetime),
+		GeneratedKey: upload.GeneratedKey,
+		OriginalName: upload.OriginalName,
+		Headers:      customerKeySessionHeaders(upload.Headers),
+	}
+	if upload.Checksum != nil {
+		session.FinalHeaders = map[string]string{"x-goog-hash": upload.Checksum.uploadHeaders()["x-goog-hash"]}
+	}
+	return session, nil
+}
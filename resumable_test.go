@@ -0,0 +1,96 @@
+package gcsurl_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/tropikoearth/gcsurl"
+)
+
+// countingTransport counts the requests sent through it
+type countingTransport struct {
+	mu       sync.Mutex
+	requests int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.requests++
+	c.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestStartResumableUploadWithOptions(t *testing.T) {
+	var started http.Header
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("x-goog-resumable") != "start" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		started = r.Header.Clone()
+		w.Header().Set("Location", "https://storage.example/upload?upload_id=session-1")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer endpoint.Close()
+
+	signer, err := gcsurl.NewTestSigner("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := &countingTransport{}
+	generator, err := gcsurl.NewURLGeneratorWithConfig(gcsurl.Config{
+		BucketName:      "documents",
+		Signer:          signer,
+		Endpoint:        endpoint.URL,
+		SignedURLClient: &http.Client{Transport: transport},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	options := gcsurl.UploadOptions{
+		UseOriginalName:   true,
+		Metadata:          map[string]string{"Owner": "u123"},
+		IfGenerationMatch: 42,
+		Checksum:          gcsurl.Checksum{CRC32C: "yZRlqg=="},
+		Storage:           gcsurl.StorageOptions{StorageClass: "NEARLINE"},
+	}
+	session, err := generator.StartResumableUploadWithOptions(ctx, "documents", "reports/q1.pdf", "https://app.example", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transport.requests != 1 {
+		t.Errorf("SignedURLClient sent %d requests, want 1", transport.requests)
+	}
+	if session.SessionURI != "https://storage.example/upload?upload_id=session-1" {
+		t.Errorf("SessionURI = %q", session.SessionURI)
+	}
+	for name, want := range map[string]string{
+		"Origin":                     "https://app.example",
+		"X-Goog-Meta-Owner":          "u123",
+		"X-Goog-If-Generation-Match": "42",
+		"X-Goog-Storage-Class":       "NEARLINE",
+	} {
+		if got := started.Get(name); got != want {
+			t.Errorf("start request %s = %q, want %q", name, got, want)
+		}
+	}
+	// Hashes apply to a request body, so the checksum goes with the final PUT
+	if started.Get("x-goog-hash") != "" || session.FinalHeaders["x-goog-hash"] != "crc32c=yZRlqg==" {
+		t.Errorf("start x-goog-hash = %q, FinalHeaders = %v", started.Get("x-goog-hash"), session.FinalHeaders)
+	}
+
+	options = gcsurl.UploadOptions{DoesNotExist: true, IfGenerationMatch: 42}
+	if _, err := generator.StartResumableUploadWithOptions(ctx, "documents", "reports/q1.pdf", "", options); !errors.Is(err, gcsurl.ErrInvalidInput) {
+		t.Errorf("conflicting preconditions: error = %v, want ErrInvalidInput", err)
+	}
+	options = gcsurl.UploadOptions{IfGenerationMatch: 42}
+	if _, err := generator.StartResumableUploadWithOptions(ctx, "documents", "reports/q1.pdf", "", options); !errors.Is(err, gcsurl.ErrInvalidInput) {
+		t.Errorf("generation match on a generated key: error = %v, want ErrInvalidInput", err)
+	}
+}
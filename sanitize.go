@@ -0,0 +1,182 @@
+package gcsurl
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// SanitizeMode selects how FilenameSanitizer handles characters outside the safe ASCII set
+type SanitizeMode string
+
+const (
+	// SanitizeTransliterate maps to ASCII letters, digits, ".", "-" and "_": "Müller Ä.pdf" -> "Muller_A.pdf"
+	SanitizeTransliterate SanitizeMode = "transliterate"
+	// SanitizePercentEncode keeps unreserved ASCII and percent-encodes the rest: "Müller.pdf" -> "M%C3%BCller.pdf"
+	SanitizePercentEncode SanitizeMode = "percent"
+	// SanitizeUnicode keeps Unicode letters and emoji but replaces reserved characters with "_"
+	SanitizeUnicode SanitizeMode = "unicode"
+)
+
+// defaultMaxFilenameBytes is the default length cap of a sanitized file name
+const defaultMaxFilenameBytes = 200
+
+// maxExtensionBytes is the longest suffix still treated as an extension
+const maxExtensionBytes = 16
+
+// reservedFilenameChars are replaced in every mode; GCS and common file systems treat them specially
+const reservedFilenameChars = `/\:*?"<>|#[]%`
+
+// transliterations covers Latin letters that do not decompose into an ASCII base letter
+var transliterations = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'Æ': "AE", 'œ': "oe", 'Œ': "OE", 'ø': "o", 'Ø': "O",
+	'đ': "d", 'Đ': "D", 'ð': "d", 'Ð': "D", 'þ': "th", 'Þ': "TH", 'ł': "l", 'Ł': "L",
+	'ı': "i", 'ħ': "h", 'Ħ': "H",
+}
+
+// Sanitizer cleans user-supplied file names before they become part of an object key
+// Set one with Config.Sanitizer; DocumentUpload.OriginalName always keeps the raw name.
+type Sanitizer interface {
+	SanitizeFilename(filename string) string
+}
+
+// FilenameSanitizer is the built-in Sanitizer
+// Names are NFC-normalized, control, hidden and bidi-override characters are removed, leading
+// dots and trailing dots and spaces are trimmed, and the name is capped at MaxBytes while the
+// extension is kept.
+type FilenameSanitizer struct {
+	// Mode selects how non-ASCII characters are handled (default: SanitizeTransliterate)
+	Mode SanitizeMode
+	// MaxBytes caps the sanitized name, extension included (default: 200)
+	MaxBytes int
+}
+
+// SanitizeFilename returns a safe version of filename
+func (s FilenameSanitizer) SanitizeFilename(filename string) string {
+	name := norm.NFC.String(filename)
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.Is(unicode.Cc, r), unicode.Is(unicode.Cf, r):
+			// Control characters, zero-width and bidi-override characters
+			return -1
+		case unicode.Is(unicode.Zs, r):
+			return ' '
+		}
+		return r
+	}, name)
+	name = strings.TrimRight(strings.TrimLeft(name, ". "), ". ")
+
+	stem, ext := name, path.Ext(name)
+	if ext == name || len(ext) > maxExtensionBytes {
+		ext = ""
+	}
+	stem = strings.TrimRight(strings.TrimSuffix(stem, ext), ". ")
+
+	extPieces := s.encode(strings.TrimPrefix(ext, "."))
+	ext = ""
+	if len(extPieces) > 0 {
+		ext = "." + strings.Join(extPieces, "")
+	}
+
+	maxBytes := s.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxFilenameBytes
+	}
+	budget := max(maxBytes-len(ext), 1)
+
+	// Cut on piece boundaries so runes and percent escapes stay intact
+	var b strings.Builder
+	for _, piece := range s.encode(stem) {
+		if b.Len()+len(piece) > budget {
+			break
+		}
+		b.WriteString(piece)
+	}
+	stem = strings.Trim(b.String(), "_ ")
+	if stem == "" {
+		stem = "file"
+	}
+	return stem + ext
+}
+
+// encode converts s into pieces of output, one per input rune (or replacement)
+func (s FilenameSanitizer) encode(text string) []string {
+	var pieces []string
+	appendPiece := func(piece string) {
+		// Collapse runs of replacement characters
+		if piece == "_" && len(pieces) > 0 && strings.HasSuffix(pieces[len(pieces)-1], "_") {
+			return
+		}
+		pieces = append(pieces, piece)
+	}
+
+	for _, r := range text {
+		switch s.Mode {
+		case SanitizePercentEncode:
+			if isUnreservedASCII(r) {
+				appendPiece(string(r))
+			} else {
+				var escaped strings.Builder
+				for _, c := range []byte(string(r)) {
+					fmt.Fprintf(&escaped, "%%%02X", c)
+				}
+				appendPiece(escaped.String())
+			}
+		case SanitizeUnicode:
+			if strings.ContainsRune(reservedFilenameChars, r) {
+				appendPiece("_")
+			} else {
+				appendPiece(string(r))
+			}
+		default:
+			appendPiece(transliterate(r))
+		}
+	}
+	return pieces
+}
+
+// transliterate maps r to safe ASCII, or "_" when there is no sensible equivalent
+func transliterate(r rune) string {
+	if r < unicode.MaxASCII {
+		if isUnreservedASCII(r) && r != '~' {
+			return string(r)
+		}
+		return "_"
+	}
+	if ascii, ok := transliterations[r]; ok {
+		return ascii
+	}
+
+	// Strip accents: "é" decomposes into "e" and a combining mark
+	var base strings.Builder
+	for _, c := range norm.NFD.String(string(r)) {
+		if unicode.Is(unicode.Mn, c) {
+			continue
+		}
+		if c >= unicode.MaxASCII || !isUnreservedASCII(c) {
+			return "_"
+		}
+		base.WriteRune(c)
+	}
+	if base.Len() == 0 {
+		return "_"
+	}
+	return base.String()
+}
+
+// isUnreservedASCII reports whether r is an RFC 3986 unreserved character
+func isUnreservedASCII(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '.' || r == '_' || r == '~'
+}
+
+// sanitizeObjectName applies the configured sanitizer to the file name part of objectName
+func (u *URLGenerator) sanitizeObjectName(objectName string) string {
+	if u.sanitizer == nil {
+		return objectName
+	}
+	dir, filename := path.Split(objectName)
+	return dir + u.sanitizer.SanitizeFilename(filename)
+}
@@ -0,0 +1,43 @@
+package gcsurl_test
+
+import (
+	"testing"
+
+	"github.com/tropikoearth/gcsurl"
+)
+
+func TestFilenameSanitizer(t *testing.T) {
+	tests := []struct {
+		name      string
+		sanitizer gcsurl.FilenameSanitizer
+		filename  string
+		want      string
+	}{
+		{"accents", gcsurl.FilenameSanitizer{}, "Müller Ä.pdf", "Muller_A.pdf"},
+		{"extension case kept", gcsurl.FilenameSanitizer{}, "résumé final.PDF", "resume_final.PDF"},
+		{"ligature", gcsurl.FilenameSanitizer{}, "Straße.txt", "Strasse.txt"},
+		{"decomposed input", gcsurl.FilenameSanitizer{}, "Cafe\u0301.txt", "Cafe.txt"},
+		{"leading dots", gcsurl.FilenameSanitizer{}, "..hidden.txt", "hidden.txt"},
+		{"trailing dots and spaces", gcsurl.FilenameSanitizer{}, "report.pdf. . ", "report.pdf"},
+		{"bidi override", gcsurl.FilenameSanitizer{}, "invoice\u202efdp.exe", "invoicefdp.exe"},
+		{"zero-width and control", gcsurl.FilenameSanitizer{}, "a\u200bb\x00c.pdf", "abc.pdf"},
+		{"reserved characters", gcsurl.FilenameSanitizer{}, `a/b\c:d*e.pdf`, "a_b_c_d_e.pdf"},
+		{"nothing left", gcsurl.FilenameSanitizer{}, "???.pdf", "file.pdf"},
+		{"empty", gcsurl.FilenameSanitizer{}, "", "file"},
+		{"non-Latin", gcsurl.FilenameSanitizer{}, "日本語.pdf", "file.pdf"},
+		{"long extension", gcsurl.FilenameSanitizer{}, "backup.verylongextension123", "backup.verylongextension123"},
+		{"length cap keeps extension", gcsurl.FilenameSanitizer{MaxBytes: 10}, "abcdefghijklmnop.pdf", "abcdef.pdf"},
+		{"percent encoding", gcsurl.FilenameSanitizer{Mode: gcsurl.SanitizePercentEncode}, "Müller Ä.pdf", "M%C3%BCller%20%C3%84.pdf"},
+		{"percent length cap keeps escapes whole", gcsurl.FilenameSanitizer{Mode: gcsurl.SanitizePercentEncode, MaxBytes: 12}, "ééé.pdf", "%C3%A9.pdf"},
+		{"unicode", gcsurl.FilenameSanitizer{Mode: gcsurl.SanitizeUnicode}, "日本語 #1.pdf", "日本語 _1.pdf"},
+		{"unicode emoji", gcsurl.FilenameSanitizer{Mode: gcsurl.SanitizeUnicode}, "party🎉?.png", "party🎉.png"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.sanitizer.SanitizeFilename(tt.filename); got != tt.want {
+				t.Errorf("SanitizeFilename(%q) = %q, want %q", tt.filename, got, tt.want)
+			}
+		})
+	}
+}
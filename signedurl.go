@@ -0,0 +1,377 @@
+package gcsurl
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// v4Algorithm is the only V4 signing algorithm the library produces
+const v4Algorithm = "GOOG4-RSA-SHA256"
+
+// v4TimeFormat is the layout of X-Goog-Date
+const v4TimeFormat = "20060102T150405Z"
+
+// maxV4Expiry is the longest expiry a V4 signature may have
+const maxV4Expiry = 7 * 24 * time.Hour
+
+// SignatureComponent names the part of a signed request that failed verification
+type SignatureComponent string
+
+const (
+	// ComponentAlgorithm is X-Goog-Algorithm
+	ComponentAlgorithm SignatureComponent = "algorithm"
+	// ComponentCredential is the access ID and credential scope in X-Goog-Credential
+	ComponentCredential SignatureComponent = "credential"
+	// ComponentExpiry is X-Goog-Date and X-Goog-Expires checked against the current time
+	ComponentExpiry SignatureComponent = "expiry"
+	// ComponentMethod is the HTTP method
+	ComponentMethod SignatureComponent = "method"
+	// ComponentPath is the canonical bucket and object path
+	ComponentPath SignatureComponent = "path"
+	// ComponentQuery is the canonical query string
+	ComponentQuery SignatureComponent = "query"
+	// ComponentHeaders is the value of a signed header
+	ComponentHeaders SignatureComponent = "headers"
+	// ComponentSignedHeaders is the X-Goog-SignedHeaders list
+	ComponentSignedHeaders SignatureComponent = "signed headers"
+	// ComponentPayload is the payload hash line of the canonical request
+	ComponentPayload SignatureComponent = "payload"
+	// ComponentSignature is the signature itself: the string-to-sign was signed by another key
+	ComponentSignature SignatureComponent = "signature"
+)
+
+// SignatureError reports which component of a signed URL failed verification
+type SignatureError struct {
+	Component        SignatureComponent
+	Reason           string
+	CanonicalRequest string // Rebuilt canonical request, once it could be built
+	StringToSign     string // Rebuilt string-to-sign, once it could be built
+}
+
+func (e *SignatureError) Error() string {
+	return fmt.Sprintf("signed URL %s mismatch: %s", e.Component, e.Reason)
+}
+
+// SignedURL is a decoded V4 signed URL
+type SignedURL struct {
+	URL             *url.URL
+	Bucket          string
+	Object          string
+	Algorithm       string        // X-Goog-Algorithm, e.g. "GOOG4-RSA-SHA256"
+	AccessID        string        // Service account that signed the URL
+	CredentialScope string        // "<yyyymmdd>/<location>/storage/goog4_request"
+	Date            time.Time     // X-Goog-Date, when the URL was signed
+	Expires         time.Duration // X-Goog-Expires
+	SignedHeaders   []string      // Lowercase header names from X-Goog-SignedHeaders
+	Signature       []byte        // Decoded X-Goog-Signature
+	Query           url.Values    // Query parameters without X-Goog-Signature, all part of the signature
+}
+
+// ParseSignedURL decodes the V4 query parameters of a signed URL
+// Both path-style (storage.googleapis.com/bucket/object) and virtual-hosted-style
+// (bucket.storage.googleapis.com/object) URLs are supported.
+func ParseSignedURL(rawURL string) (*SignedURL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signed URL: %w", err)
+	}
+	query := u.Query()
+	for _, name := range []string{"X-Goog-Algorithm", "X-Goog-Credential", "X-Goog-Date", "X-Goog-Expires", "X-Goog-SignedHeaders", "X-Goog-Signature"} {
+		if len(query[name]) != 1 {
+			return nil, fmt.Errorf("signed URL must have exactly one %s parameter", name)
+		}
+	}
+
+	s := &SignedURL{URL: u, Algorithm: query.Get("X-Goog-Algorithm")}
+	s.Bucket, s.Object = splitBucketObject(u)
+
+	credential := query.Get("X-Goog-Credential")
+	var ok bool
+	if s.AccessID, s.CredentialScope, ok = strings.Cut(credential, "/"); !ok {
+		return nil, fmt.Errorf("X-Goog-Credential %q has no credential scope", credential)
+	}
+	if s.Date, err = time.Parse(v4TimeFormat, query.Get("X-Goog-Date")); err != nil {
+		return nil, fmt.Errorf("invalid X-Goog-Date %q", query.Get("X-Goog-Date"))
+	}
+	seconds, err := strconv.ParseInt(query.Get("X-Goog-Expires"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid X-Goog-Expires %q", query.Get("X-Goog-Expires"))
+	}
+	s.Expires = time.Duration(seconds) * time.Second
+	s.SignedHeaders = strings.Split(query.Get("X-Goog-SignedHeaders"), ";")
+	if s.Signature, err = hex.DecodeString(query.Get("X-Goog-Signature")); err != nil {
+		return nil, fmt.Errorf("X-Goog-Signature is not hex encoded: %w", err)
+	}
+
+	query.Del("X-Goog-Signature")
+	s.Query = query
+	return s, nil
+}
+
+// splitBucketObject returns the bucket and object of a path-style or virtual-hosted-style URL
+func splitBucketObject(u *url.URL) (string, string) {
+	path := strings.TrimPrefix(u.Path, "/")
+	if bucket, ok := strings.CutSuffix(u.Hostname(), ".storage.googleapis.com"); ok {
+		return bucket, path
+	}
+	bucket, object, _ := strings.Cut(path, "/")
+	return bucket, object
+}
+
+// ExpiresAt returns when the URL stops being valid
+func (s *SignedURL) ExpiresAt() time.Time {
+	return s.Date.Add(s.Expires)
+}
+
+// CanonicalRequest rebuilds the canonical request GCS computes for a request to the URL
+// headers are the headers the client sends; every signed header except host must be present.
+func (s *SignedURL) CanonicalRequest(method string, headers http.Header) (string, error) {
+	var canonicalHeaders strings.Builder
+	for _, name := range s.SignedHeaders {
+		var value string
+		if name == "host" {
+			value = s.URL.Hostname()
+		} else {
+			values := headers.Values(name)
+			if len(values) == 0 {
+				return "", &SignatureError{Component: ComponentHeaders, Reason: fmt.Sprintf("signed header %s is not sent", name)}
+			}
+			value = strings.Join(values, ",")
+		}
+		// Runs of whitespace are folded into a single space
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, strings.Join(strings.Fields(value), " "))
+	}
+
+	payload := "UNSIGNED-PAYLOAD"
+	if slices.Contains(s.SignedHeaders, "x-goog-content-sha256") {
+		payload = headers.Get("x-goog-content-sha256")
+	}
+
+	return strings.Join([]string{
+		method,
+		"/" + escapeV4Path(strings.TrimPrefix(s.URL.Path, "/")),
+		strings.ReplaceAll(s.Query.Encode(), "+", "%20"),
+		canonicalHeaders.String(),
+		strings.Join(s.SignedHeaders, ";"),
+		payload,
+	}, "\n"), nil
+}
+
+// escapeV4Path escapes each path segment as the V4 signing spec requires
+func escapeV4Path(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = strings.ReplaceAll(url.QueryEscape(segment), "+", "%20")
+	}
+	return strings.Join(segments, "/")
+}
+
+// StringToSign returns the string-to-sign for a canonical request
+func (s *SignedURL) StringToSign(canonicalRequest string) string {
+	sum := sha256.Sum256([]byte(canonicalRequest))
+	return strings.Join([]string{s.Algorithm, s.Date.Format(v4TimeFormat), s.CredentialScope, hex.EncodeToString(sum[:])}, "\n")
+}
+
+// SignatureVerifyOptions describes the request a signed URL is verified against
+type SignatureVerifyOptions struct {
+	// Method is the HTTP method the client uses, e.g. "PUT"
+	Method string
+	// Headers are the headers the client sends
+	Headers http.Header
+	// PublicKey is the signer's key; see ParsePublicKeyPEM
+	PublicKey *rsa.PublicKey
+	// Now is the time expiry is checked at (default: time.Now())
+	Now time.Time
+	// ExpectedCanonicalRequest is the CanonicalRequest from a GCS SignatureDoesNotMatch response
+	// When set, it is compared line by line to name the component that differs.
+	ExpectedCanonicalRequest string
+}
+
+// Verify rebuilds the canonical request and string-to-sign and checks the signature offline
+// A failure is a *SignatureError naming the component that mismatched.
+func (s *SignedURL) Verify(options SignatureVerifyOptions) error {
+	if options.PublicKey == nil {
+		return fmt.Errorf("public key is required to verify a signed URL")
+	}
+	if options.Method == "" {
+		return &SignatureError{Component: ComponentMethod, Reason: "method is required"}
+	}
+
+	if s.Algorithm != v4Algorithm {
+		return &SignatureError{Component: ComponentAlgorithm, Reason: fmt.Sprintf("algorithm is %q, expected %q", s.Algorithm, v4Algorithm)}
+	}
+	if err := s.checkCredentialScope(); err != nil {
+		return err
+	}
+	if err := s.checkSignedHeaders(); err != nil {
+		return err
+	}
+
+	canonicalRequest, err := s.CanonicalRequest(options.Method, options.Headers)
+	if err != nil {
+		return err
+	}
+	stringToSign := s.StringToSign(canonicalRequest)
+	mismatch := func(component SignatureComponent, reason string) error {
+		return &SignatureError{Component: component, Reason: reason, CanonicalRequest: canonicalRequest, StringToSign: stringToSign}
+	}
+
+	if options.ExpectedCanonicalRequest != "" {
+		if component, reason := diffCanonicalRequest(canonicalRequest, options.ExpectedCanonicalRequest); component != "" {
+			return mismatch(component, reason)
+		}
+	}
+
+	if !s.signatureMatches(options.PublicKey, stringToSign) {
+		// A URL used with the wrong method is the most common cause; name it when it is the cause
+		for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPost, http.MethodDelete} {
+			if method == options.Method {
+				continue
+			}
+			if other, err := s.CanonicalRequest(method, options.Headers); err == nil && s.signatureMatches(options.PublicKey, s.StringToSign(other)) {
+				return mismatch(ComponentMethod, fmt.Sprintf("URL is signed for %s, not %s", method, options.Method))
+			}
+		}
+		return mismatch(ComponentSignature, "signature does not match the string-to-sign for this key and these headers")
+	}
+
+	now := options.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if s.Expires <= 0 || s.Expires > maxV4Expiry {
+		return mismatch(ComponentExpiry, fmt.Sprintf("expiry %s is outside 1s-%s", s.Expires, maxV4Expiry))
+	}
+	if now.Before(s.Date) {
+		return mismatch(ComponentExpiry, fmt.Sprintf("URL is not valid before %s", s.Date.Format(time.RFC3339)))
+	}
+	if !now.Before(s.ExpiresAt()) {
+		return mismatch(ComponentExpiry, fmt.Sprintf("URL expired at %s", s.ExpiresAt().Format(time.RFC3339)))
+	}
+	return nil
+}
+
+// signatureMatches reports whether the URL's signature is valid for stringToSign
+func (s *SignedURL) signatureMatches(key *rsa.PublicKey, stringToSign string) bool {
+	sum := sha256.Sum256([]byte(stringToSign))
+	return rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], s.Signature) == nil
+}
+
+// checkCredentialScope checks the scope format and that its date matches X-Goog-Date
+func (s *SignedURL) checkCredentialScope() error {
+	parts := strings.Split(s.CredentialScope, "/")
+	if len(parts) != 4 || parts[1] == "" || parts[2] != "storage" || parts[3] != "goog4_request" {
+		return &SignatureError{Component: ComponentCredential, Reason: fmt.Sprintf("credential scope %q must be <date>/<location>/storage/goog4_request", s.CredentialScope)}
+	}
+	if date := s.Date.Format("20060102"); parts[0] != date {
+		return &SignatureError{Component: ComponentCredential, Reason: fmt.Sprintf("credential scope date %s does not match X-Goog-Date %s", parts[0], date)}
+	}
+	if s.AccessID == "" {
+		return &SignatureError{Component: ComponentCredential, Reason: "access ID is empty"}
+	}
+	return nil
+}
+
+// checkSignedHeaders checks that the signed headers are lowercase, sorted and include host
+func (s *SignedURL) checkSignedHeaders() error {
+	for i, name := range s.SignedHeaders {
+		if name == "" || name != strings.ToLower(name) {
+			return &SignatureError{Component: ComponentSignedHeaders, Reason: fmt.Sprintf("header name %q must be non-empty lowercase", name)}
+		}
+		if i > 0 && name <= s.SignedHeaders[i-1] {
+			return &SignatureError{Component: ComponentSignedHeaders, Reason: fmt.Sprintf("header names must be sorted and unique, %q follows %q", name, s.SignedHeaders[i-1])}
+		}
+	}
+	if !slices.Contains(s.SignedHeaders, "host") {
+		return &SignatureError{Component: ComponentSignedHeaders, Reason: "host must be signed"}
+	}
+	return nil
+}
+
+// diffCanonicalRequest returns the component of the first line that differs between two canonical requests
+func diffCanonicalRequest(actual, expected string) (SignatureComponent, string) {
+	actualLines := strings.Split(actual, "\n")
+	expectedLines := strings.Split(strings.ReplaceAll(strings.TrimSpace(expected), "\r\n", "\n"), "\n")
+
+	// Lines are method, path, query, one line per header, a blank line, signed headers, payload
+	component := func(i int, lines []string) SignatureComponent {
+		switch {
+		case i == 0:
+			return ComponentMethod
+		case i == 1:
+			return ComponentPath
+		case i == 2:
+			return ComponentQuery
+		case i == len(lines)-1:
+			return ComponentPayload
+		case i == len(lines)-2:
+			return ComponentSignedHeaders
+		}
+		return ComponentHeaders
+	}
+
+	for i := range max(len(actualLines), len(expectedLines)) {
+		var a, e string
+		if i < len(actualLines) {
+			a = actualLines[i]
+		}
+		if i < len(expectedLines) {
+			e = expectedLines[i]
+		}
+		if a != e {
+			return component(i, actualLines), fmt.Sprintf("canonical request line %d is %q, GCS computed %q", i+1, a, e)
+		}
+	}
+	return "", ""
+}
+
+// ParsePublicKeyPEM returns the RSA public key in a PEM certificate, public key or private key
+// Google publishes the certificates of a service account's keys at
+// https://www.googleapis.com/service_accounts/v1/metadata/x509/<email>.
+func ParsePublicKeyPEM(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+			key = cert.PublicKey
+		}
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PRIVATE KEY", "RSA PRIVATE KEY":
+		var private *rsa.PrivateKey
+		if private, err = parseRSAPrivateKey(data); err == nil {
+			key = &private.PublicKey
+		}
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", strings.ToLower(block.Type), err)
+	}
+
+	publicKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("key is %T, not an RSA key", key)
+	}
+	return publicKey, nil
+}
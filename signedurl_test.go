@@ -0,0 +1,151 @@
+package gcsurl_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tropikoearth/gcsurl"
+)
+
+// newSignedURLGenerator returns a generator signing with a fresh test key
+func newSignedURLGenerator(t *testing.T) (*gcsurl.URLGenerator, *gcsurl.TestSigner) {
+	t.Helper()
+	signer, err := gcsurl.NewTestSigner("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	generator, err := gcsurl.NewURLGeneratorWithConfig(gcsurl.Config{BucketName: "documents", Signer: signer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return generator, signer
+}
+
+// headerOf converts signed upload headers into the header a client sends
+func headerOf(headers map[string]string) http.Header {
+	header := make(http.Header)
+	for name, value := range headers {
+		header.Set(name, value)
+	}
+	return header
+}
+
+func TestSignedURLRoundTrip(t *testing.T) {
+	generator, signer := newSignedURLGenerator(t)
+	ctx := context.Background()
+
+	upload, err := generator.GenerateSignedUploadURLWithOptions(ctx, "documents", "reports/Q1 2025.pdf", gcsurl.UploadOptions{
+		Metadata:     map[string]string{"owner": "u123"},
+		Checksum:     gcsurl.Checksum{MD5: "1B2M2Y8AsgTpgAmY7PhCfg=="},
+		DoesNotExist: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	download, err := generator.GenerateSignedDownloadWithOptions(ctx, "documents", upload.GeneratedKey, gcsurl.DownloadOptions{Disposition: gcsurl.DispositionAttachment})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleteURL, err := generator.GenerateSignedDeleteURL(ctx, upload.GeneratedKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	headURL, err := generator.GenerateSignedHeadURL(ctx, upload.GeneratedKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		url     string
+		method  string
+		headers http.Header
+	}{
+		{"upload", upload.UploadURL, http.MethodPut, headerOf(upload.Headers)},
+		{"download", download.DownloadURL, http.MethodGet, headerOf(download.Headers)},
+		{"delete", deleteURL, http.MethodDelete, nil},
+		{"head", headURL, http.MethodHead, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signed, err := gcsurl.ParseSignedURL(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if signed.Bucket != "documents" || signed.Object != upload.GeneratedKey {
+				t.Errorf("parsed %s/%s, want documents/%s", signed.Bucket, signed.Object, upload.GeneratedKey)
+			}
+			if accessID, _ := signer.AccessID(); signed.AccessID != accessID {
+				t.Errorf("AccessID = %q, want %q", signed.AccessID, accessID)
+			}
+			if err := signed.Verify(gcsurl.SignatureVerifyOptions{Method: tt.method, Headers: tt.headers, PublicKey: signer.PublicKey()}); err != nil {
+				t.Errorf("Verify() = %v", err)
+			}
+		})
+	}
+}
+
+func TestSignedURLVerifyFailures(t *testing.T) {
+	generator, signer := newSignedURLGenerator(t)
+	other, err := gcsurl.NewTestSigner("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upload, err := generator.GenerateSignedUploadURLWithOptions(context.Background(), "documents", "reports/q1.pdf", gcsurl.UploadOptions{
+		Metadata: map[string]string{"owner": "u123"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := gcsurl.ParseSignedURL(upload.UploadURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := headerOf(upload.Headers)
+	tampered.Set("x-goog-meta-owner", "u999")
+	missing := headerOf(upload.Headers)
+	missing.Del("x-goog-meta-owner")
+	canonical, err := signed.CanonicalRequest(http.MethodPut, headerOf(upload.Headers))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		options gcsurl.SignatureVerifyOptions
+		want    gcsurl.SignatureComponent
+	}{
+		{"wrong method", gcsurl.SignatureVerifyOptions{Method: http.MethodPost, Headers: headerOf(upload.Headers)}, gcsurl.ComponentMethod},
+		{"missing header", gcsurl.SignatureVerifyOptions{Method: http.MethodPut, Headers: missing}, gcsurl.ComponentHeaders},
+		{"tampered header", gcsurl.SignatureVerifyOptions{Method: http.MethodPut, Headers: tampered}, gcsurl.ComponentSignature},
+		{"other key", gcsurl.SignatureVerifyOptions{Method: http.MethodPut, Headers: headerOf(upload.Headers), PublicKey: other.PublicKey()}, gcsurl.ComponentSignature},
+		{"expired", gcsurl.SignatureVerifyOptions{Method: http.MethodPut, Headers: headerOf(upload.Headers), Now: time.Now().Add(time.Hour)}, gcsurl.ComponentExpiry},
+		{"GCS computed another path", gcsurl.SignatureVerifyOptions{Method: http.MethodPut, Headers: headerOf(upload.Headers), ExpectedCanonicalRequest: replaceLine(canonical, 1, "/documents/other.pdf")}, gcsurl.ComponentPath},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.options.PublicKey == nil {
+				tt.options.PublicKey = signer.PublicKey()
+			}
+			var sigErr *gcsurl.SignatureError
+			if err := signed.Verify(tt.options); !errors.As(err, &sigErr) || sigErr.Component != tt.want {
+				t.Errorf("Verify() = %v, want a %s mismatch", err, tt.want)
+			}
+		})
+	}
+}
+
+// replaceLine replaces one line of a canonical request
+func replaceLine(canonicalRequest string, i int, line string) string {
+	lines := strings.Split(canonicalRequest, "\n")
+	lines[i] = line
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,203 @@
+package gcsurl
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// slotTokenHeader is the metadata header written when a slot is reserved
+const slotTokenHeader = "x-goog-meta-gcsurl-slot-token"
+
+// preservedSlotHeaders are object headers kept when a slot is re-reserved in place
+var preservedSlotHeaders = []string{
+	"Content-Type", "Cache-Control", "Content-Disposition", "Content-Encoding", "Content-Language",
+	"x-goog-storage-class", "x-goog-encryption-kms-key-name",
+}
+
+// GenerateSignedSlotUploadURL generates a single-use upload URL for a logical slot in the default bucket
+// A slot is a fixed object key such as "users/123/avatar.jpg". Each call reserves the slot by
+// moving it to a new generation and signs the URL with x-goog-if-generation-match for that
+// generation, so the URL works once and every URL issued earlier for the slot stops working.
+func (u *URLGenerator) GenerateSignedSlotUploadURL(ctx context.Context, slotName string) (DocumentUpload, error) {
+	return u.GenerateSignedSlotUploadURLWithBucket(ctx, u.bucketName, slotName)
+}
+
+// GenerateSignedSlotUploadURLWithBucket generates a single-use slot upload URL for a specific bucket
+// An existing object in the slot keeps its content, storage class and encryption until the new
+// upload replaces it. An empty slot holds a zero-byte placeholder until then, carrying
+// x-goog-custom-time so a lifecycle rule can delete placeholders whose URL was never used.
+func (u *URLGenerator) GenerateSignedSlotUploadURLWithBucket(ctx context.Context, bucketName, slotName string) (DocumentUpload, error) {
+	key, err := u.uploadKey(slotName, "", nil, true)
+	if err != nil {
+		return DocumentUpload{}, err
+	}
+	if err := u.authorize(ctx, OperationUpload, bucketName, key); err != nil {
+		return DocumentUpload{}, err
+	}
+
+	encryptionKey, err := u.encryptionKey(ctx, nil, bucketName, key)
+	if err != nil {
+		return DocumentUpload{}, err
+	}
+
+	headers := map[string]string{"Content-Type": "application/octet-stream"}
+	if u.hasRestrictions() {
+		if err := u.ValidateUpload(slotName); err != nil {
+			return DocumentUpload{}, err
+		}
+		headers = u.restrictedUploadHeaders(slotName)
+	}
+
+	storageOptions, err := u.storageOptions(bucketName, StorageOptions{})
+	if err != nil {
+		return DocumentUpload{}, err
+	}
+	for name, value := range storageOptions.headers() {
+		headers[name] = value
+	}
+	for name, value := range encryptionKey.uploadHeaders() {
+		headers[name] = value
+	}
+
+	generation, err := u.reserveSlot(ctx, bucketName, key, encryptionKey)
+	if err != nil {
+		return DocumentUpload{}, fmt.Errorf("failed to reserve upload slot %s: %w", key, err)
+	}
+	headers["x-goog-if-generation-match"] = strconv.FormatInt(generation, 10)
+
+	expires := time.Now().Add(u.defaultExpiry)
+	opts, err := u.newSignedURLOptions(ctx, "PUT", expires)
+	if err != nil {
+		return DocumentUpload{}, err
+	}
+	applyHeaders(opts, headers)
+
+	signedURL, err := storage.SignedURL(bucketName, key, opts)
+	if err != nil {
+		return DocumentUpload{}, fmt.Errorf("failed to generate signed slot upload URL: %w", err)
+	}
+
+	return DocumentUpload{
+		UploadURL:    signedURL,
+		ExpiresAt:    expires,
+		GeneratedKey: key,
+		OriginalName: slotName,
+		Headers:      headers,
+	}, nil
+}
+
+// reserveSlot moves the slot object to a new generation and returns it
+// Empty slots get a zero-byte placeholder; occupied slots are copied onto themselves, keeping
+// their metadata, storage class and encryption key.
+func (u *URLGenerator) reserveSlot(ctx context.Context, bucketName, slotName string, encryptionKey *EncryptionKey) (int64, error) {
+	token, err := generateSlotToken()
+	if err != nil {
+		return 0, err
+	}
+
+	// Signed URLs are only used server-side and immediately, so a short expiry is enough
+	expiry := time.Minute
+
+	// Reading a CSEK object's metadata needs its key
+	customerKeyHeaders := encryptionKey.customerKeyHeaders()
+	headURL, err := u.signSlotURL(ctx, "HEAD", bucketName, slotName, expiry, customerKeyHeaders)
+	if err != nil {
+		return 0, err
+	}
+	status, current, _, err := u.sendSignedRequest(ctx, "HEAD", headURL, customerKeyHeaders, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var headers map[string]string
+	switch status {
+	case http.StatusNotFound:
+		headers = map[string]string{
+			"Content-Type":               "application/octet-stream",
+			"x-goog-custom-time":         time.Now().UTC().Format(time.RFC3339),
+			"x-goog-if-generation-match": "0",
+			slotTokenHeader:              token,
+		}
+	case http.StatusOK:
+		generation := current.Get("x-goog-generation")
+		headers = map[string]string{
+			"x-goog-copy-source":                     "/" + bucketName + "/" + escapeV4Path(slotName),
+			"x-goog-copy-source-if-generation-match": generation,
+			"x-goog-if-generation-match":             generation,
+			"x-goog-metadata-directive":              "REPLACE",
+		}
+		for _, name := range preservedSlotHeaders {
+			if value := current.Get(name); value != "" {
+				headers[name] = value
+			}
+		}
+		for name, values := range current {
+			if name = strings.ToLower(name); strings.HasPrefix(name, "x-goog-meta-") && len(values) > 0 {
+				headers[name] = values[0]
+			}
+		}
+		headers[slotTokenHeader] = token
+
+		// A CSEK object is decrypted with the key and encrypted again with it
+		for name, value := range customerKeyHeaders {
+			headers[strings.Replace(name, "x-goog-", "x-goog-copy-source-", 1)] = value
+		}
+	default:
+		return 0, fmt.Errorf("unexpected status %d checking slot", status)
+	}
+	for name, value := range encryptionKey.uploadHeaders() {
+		headers[name] = value
+	}
+
+	putURL, err := u.signSlotURL(ctx, "PUT", bucketName, slotName, expiry, headers)
+	if err != nil {
+		return 0, err
+	}
+	status, reserved, body, err := u.sendSignedRequest(ctx, "PUT", putURL, headers, nil)
+	if err != nil {
+		return 0, err
+	}
+	if status == http.StatusPreconditionFailed {
+		return 0, fmt.Errorf("slot changed while it was being reserved, retry")
+	}
+	if status != http.StatusOK {
+		return 0, fmt.Errorf("status %d: %s", status, strings.TrimSpace(string(body)))
+	}
+
+	generation, err := strconv.ParseInt(reserved.Get("x-goog-generation"), 10, 64)
+	if err != nil || generation <= 0 {
+		return 0, fmt.Errorf("response has no valid x-goog-generation header")
+	}
+	return generation, nil
+}
+
+// signSlotURL signs a short-lived URL used while reserving a slot
+func (u *URLGenerator) signSlotURL(ctx context.Context, method, bucketName, slotName string, expiry time.Duration, headers map[string]string) (string, error) {
+	opts, err := u.newSignedURLOptions(ctx, method, time.Now().Add(expiry))
+	if err != nil {
+		return "", err
+	}
+	applyHeaders(opts, headers)
+
+	signedURL, err := storage.SignedURL(bucketName, slotName, opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate signed slot %s URL: %w", method, err)
+	}
+	return signedURL, nil
+}
+
+// generateSlotToken generates a random token identifying a slot reservation
+func generateSlotToken() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("failed to generate slot token: %w", err)
+	}
+	return fmt.Sprintf("%x", bytes), nil
+}
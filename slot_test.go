@@ -0,0 +1,114 @@
+package gcsurl_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/tropikoearth/gcsurl"
+	"github.com/tropikoearth/gcsurl/gcsurltest"
+)
+
+// putUpload sends an upload with its signed headers and returns the status
+func putUpload(t *testing.T, upload gcsurl.DocumentUpload, data []byte) int {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPut, upload.UploadURL, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range upload.Headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestSlotUploadInvalidatesEarlierURLs(t *testing.T) {
+	server, err := gcsurltest.NewServer(gcsurltest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	customerKey := bytes.Repeat([]byte{7}, 32)
+	config := server.Config("avatars")
+	config.EncryptionKeys = gcsurl.EncryptionKeyProviderFunc(func(ctx context.Context, bucketName, objectName string) (*gcsurl.EncryptionKey, error) {
+		return &gcsurl.EncryptionKey{CustomerKey: customerKey}, nil
+	})
+	generator, err := gcsurl.NewURLGeneratorWithConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	slot := "users/123/my avatar.jpg"
+	first, err := generator.GenerateSignedSlotUploadURL(ctx, slot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	placeholder, ok := server.Object("avatars", slot)
+	if !ok || len(placeholder.Data) != 0 {
+		t.Fatalf("empty slot has no placeholder: %+v", placeholder)
+	}
+
+	// Re-reserving copies the CSEK placeholder onto itself through an escaped copy source
+	second, err := generator.GenerateSignedSlotUploadURL(ctx, slot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := putUpload(t, first, []byte("first")); status != http.StatusPreconditionFailed {
+		t.Errorf("earlier slot URL: status %d, want 412", status)
+	}
+	if status := putUpload(t, second, []byte("second")); status != http.StatusOK {
+		t.Fatalf("latest slot URL: status %d, want 200", status)
+	}
+	if status := putUpload(t, second, []byte("again")); status != http.StatusPreconditionFailed {
+		t.Errorf("reused slot URL: status %d, want 412", status)
+	}
+
+	stored, _ := server.Object("avatars", slot)
+	if string(stored.Data) != "second" || stored.CustomerKeySHA256 == "" {
+		t.Errorf("stored object = %q encrypted %v, want the second upload encrypted with the key", stored.Data, stored.CustomerKeySHA256 != "")
+	}
+}
+
+func TestSlotReservationKeepsStorageClass(t *testing.T) {
+	server, err := gcsurltest.NewServer(gcsurltest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	ctx := context.Background()
+	config := server.Config("avatars")
+	config.BucketStorageOptions = map[string]gcsurl.StorageOptions{"avatars": {StorageClass: "NEARLINE"}}
+	nearline, err := gcsurl.NewURLGeneratorWithConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upload, err := nearline.GenerateSignedSlotUploadURL(ctx, "users/123/avatar.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := putUpload(t, upload, []byte("avatar")); status != http.StatusOK {
+		t.Fatalf("slot upload: status %d, want 200", status)
+	}
+
+	// A generator without bucket defaults reserves the slot again
+	generator, err := gcsurl.NewURLGeneratorWithConfig(server.Config("avatars"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := generator.GenerateSignedSlotUploadURL(ctx, "users/123/avatar.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := server.Object("avatars", "users/123/avatar.jpg")
+	if string(stored.Data) != "avatar" || stored.StorageClass != "NEARLINE" {
+		t.Errorf("reserved slot = %q in %s, want the avatar kept in NEARLINE", stored.Data, stored.StorageClass)
+	}
+}
@@ -0,0 +1,197 @@
+package gcsurl
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// StorageOptions sets object attributes that are signed as headers of an upload URL
+// Empty fields are not signed; per-request options override the bucket's Config.BucketStorageOptions.
+type StorageOptions struct {
+	// StorageClass is signed as x-goog-storage-class, e.g. "ARCHIVE" for backups
+	StorageClass string `json:"storageClass,omitempty"`
+	// CacheControl is signed as Cache-Control, e.g. "public, max-age=31536000, immutable"
+	CacheControl string `json:"cacheControl,omitempty"`
+	// ContentEncoding is signed as Content-Encoding, e.g. "gzip" for pre-compressed files
+	ContentEncoding string `json:"contentEncoding,omitempty"`
+	// ContentLanguage is signed as Content-Language, e.g. "en" or "pt-BR"
+	ContentLanguage string `json:"contentLanguage,omitempty"`
+	// ACL is a predefined ACL signed as x-goog-acl, e.g. "public-read"
+	// Buckets with uniform bucket-level access reject uploads that set it.
+	ACL string `json:"acl,omitempty"`
+}
+
+// storageClasses are the storage classes GCS accepts for objects
+var storageClasses = []string{"STANDARD", "NEARLINE", "COLDLINE", "ARCHIVE", "MULTI_REGIONAL", "REGIONAL", "DURABLE_REDUCED_AVAILABILITY"}
+
+// predefinedACLs are the XML API canned ACLs that apply to objects
+var predefinedACLs = []string{"private", "bucket-owner-read", "bucket-owner-full-control", "project-private", "authenticated-read", "public-read"}
+
+// contentEncodings are the accepted Content-Encoding values
+var contentEncodings = []string{"gzip", "br", "deflate", "zstd", "compress", "identity"}
+
+// cacheDirectives maps Cache-Control directives to whether they take a number of seconds
+var cacheDirectives = map[string]bool{
+	"public":                 false,
+	"private":                false,
+	"no-cache":               false,
+	"no-store":               false,
+	"no-transform":           false,
+	"must-revalidate":        false,
+	"proxy-revalidate":       false,
+	"immutable":              false,
+	"max-age":                true,
+	"s-maxage":               true,
+	"stale-while-revalidate": true,
+	"stale-if-error":         true,
+}
+
+// languageTagPattern matches BCP 47 style language tags such as "en", "pt-BR" or "zh-Hant-TW"
+var languageTagPattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{1,8})*$`)
+
+// normalize validates the options against the allowed values and returns their canonical form
+func (o StorageOptions) normalize() (StorageOptions, error) {
+	if o.StorageClass != "" {
+		o.StorageClass = strings.ToUpper(strings.TrimSpace(o.StorageClass))
+		if !slices.Contains(storageClasses, o.StorageClass) {
+			return StorageOptions{}, inputErrorf("storage class %q is not one of %s", o.StorageClass, strings.Join(storageClasses, ", "))
+		}
+	}
+	if o.ACL != "" {
+		o.ACL = strings.ToLower(strings.TrimSpace(o.ACL))
+		if !slices.Contains(predefinedACLs, o.ACL) {
+			return StorageOptions{}, inputErrorf("ACL %q is not one of %s", o.ACL, strings.Join(predefinedACLs, ", "))
+		}
+	}
+	if o.ContentEncoding != "" {
+		o.ContentEncoding = strings.ToLower(strings.TrimSpace(o.ContentEncoding))
+		if !slices.Contains(contentEncodings, o.ContentEncoding) {
+			return StorageOptions{}, inputErrorf("content encoding %q is not one of %s", o.ContentEncoding, strings.Join(contentEncodings, ", "))
+		}
+	}
+	if o.ContentLanguage != "" {
+		o.ContentLanguage = strings.TrimSpace(o.ContentLanguage)
+		if !languageTagPattern.MatchString(o.ContentLanguage) {
+			return StorageOptions{}, inputErrorf("content language %q is not a valid language tag", o.ContentLanguage)
+		}
+	}
+	if o.CacheControl != "" {
+		cacheControl, err := normalizeCacheControl(o.CacheControl)
+		if err != nil {
+			return StorageOptions{}, err
+		}
+		o.CacheControl = cacheControl
+	}
+	return o, nil
+}
+
+// normalizeCacheControl validates the directives of a Cache-Control value
+func normalizeCacheControl(value string) (string, error) {
+	var directives []string
+	seen := make(map[string]bool)
+	for _, directive := range strings.Split(value, ",") {
+		name, seconds, hasValue := strings.Cut(strings.TrimSpace(directive), "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		takesSeconds, ok := cacheDirectives[name]
+		if !ok {
+			return "", inputErrorf("unsupported Cache-Control directive %q", name)
+		}
+		if seen[name] {
+			return "", inputErrorf("Cache-Control directive %q is repeated", name)
+		}
+		seen[name] = true
+
+		if takesSeconds != hasValue {
+			if takesSeconds {
+				return "", inputErrorf("Cache-Control directive %q requires a number of seconds", name)
+			}
+			return "", inputErrorf("Cache-Control directive %q does not take a value", name)
+		}
+		if takesSeconds {
+			n, err := strconv.ParseUint(strings.TrimSpace(seconds), 10, 32)
+			if err != nil {
+				return "", inputErrorf("Cache-Control %s must be a number of seconds, got %q", name, seconds)
+			}
+			name += "=" + strconv.FormatUint(n, 10)
+		}
+		directives = append(directives, name)
+	}
+	if seen["public"] && seen["private"] {
+		return "", inputErrorf("Cache-Control cannot be both public and private")
+	}
+	if len(directives) == 0 {
+		return "", inputErrorf("Cache-Control %q has no directives", value)
+	}
+	return strings.Join(directives, ", "), nil
+}
+
+// merge returns o with the non-empty fields of override applied
+func (o StorageOptions) merge(override StorageOptions) StorageOptions {
+	if override.StorageClass != "" {
+		o.StorageClass = override.StorageClass
+	}
+	if override.CacheControl != "" {
+		o.CacheControl = override.CacheControl
+	}
+	if override.ContentEncoding != "" {
+		o.ContentEncoding = override.ContentEncoding
+	}
+	if override.ContentLanguage != "" {
+		o.ContentLanguage = override.ContentLanguage
+	}
+	if override.ACL != "" {
+		o.ACL = override.ACL
+	}
+	return o
+}
+
+// headers returns the upload headers that set the options
+func (o StorageOptions) headers() map[string]string {
+	headers := make(map[string]string)
+	if o.StorageClass != "" {
+		headers["x-goog-storage-class"] = o.StorageClass
+	}
+	if o.CacheControl != "" {
+		headers["Cache-Control"] = o.CacheControl
+	}
+	if o.ContentEncoding != "" {
+		headers["Content-Encoding"] = o.ContentEncoding
+	}
+	if o.ContentLanguage != "" {
+		headers["Content-Language"] = o.ContentLanguage
+	}
+	if o.ACL != "" {
+		headers["x-goog-acl"] = o.ACL
+	}
+	return headers
+}
+
+// normalizeBucketStorageOptions validates the per-bucket storage options from the config
+func normalizeBucketStorageOptions(options map[string]StorageOptions) (map[string]StorageOptions, error) {
+	if len(options) == 0 {
+		return nil, nil
+	}
+	normalized := make(map[string]StorageOptions, len(options))
+	for bucketName, bucketOptions := range options {
+		var err error
+		if normalized[bucketName], err = bucketOptions.normalize(); err != nil {
+			return nil, fmt.Errorf("invalid storage options for bucket %s: %w", bucketName, err)
+		}
+	}
+	return normalized, nil
+}
+
+// storageOptions returns the bucket's storage options with the per-request override applied
+func (u *URLGenerator) storageOptions(bucketName string, override StorageOptions) (StorageOptions, error) {
+	override, err := override.normalize()
+	if err != nil {
+		return StorageOptions{}, err
+	}
+	return u.bucketStorageOptions[bucketName].merge(override), nil
+}
@@ -0,0 +1,118 @@
+package gcsurl
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStorageOptionsNormalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		options StorageOptions
+		want    StorageOptions
+		wantErr bool
+	}{
+		{"empty", StorageOptions{}, StorageOptions{}, false},
+		{"canonical case", StorageOptions{StorageClass: " nearline ", ACL: "Public-Read", ContentEncoding: "GZIP"}, StorageOptions{StorageClass: "NEARLINE", ACL: "public-read", ContentEncoding: "gzip"}, false},
+		{"language tag", StorageOptions{ContentLanguage: " pt-BR "}, StorageOptions{ContentLanguage: "pt-BR"}, false},
+		{"cache control", StorageOptions{CacheControl: "Public,max-age = 60"}, StorageOptions{CacheControl: "public, max-age=60"}, false},
+		{"unknown storage class", StorageOptions{StorageClass: "COLD"}, StorageOptions{}, true},
+		{"unknown ACL", StorageOptions{ACL: "public-read-write"}, StorageOptions{}, true},
+		{"unknown encoding", StorageOptions{ContentEncoding: "lzma"}, StorageOptions{}, true},
+		{"invalid language", StorageOptions{ContentLanguage: "english (US)"}, StorageOptions{}, true},
+		{"invalid cache control", StorageOptions{CacheControl: "forever"}, StorageOptions{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.options.normalize()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidInput) {
+				t.Errorf("normalize() error = %v, want ErrInvalidInput", err)
+			}
+			if got != tt.want {
+				t.Errorf("normalize() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeCacheControl(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"public, max-age=31536000, immutable", "public, max-age=31536000, immutable", false},
+		{"NO-STORE", "no-store", false},
+		{"private,, s-maxage=0", "private, s-maxage=0", false},
+		{"max-age=007", "max-age=7", false},
+		{"", "", true},
+		{" , ", "", true},
+		{"max-age", "", true},
+		{"max-age=-1", "", true},
+		{"max-age=1h", "", true},
+		{"max-age=99999999999", "", true},
+		{"no-store=1", "", true},
+		{"public, private", "", true},
+		{"no-cache, no-cache", "", true},
+		{"max-stale=60", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := normalizeCacheControl(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("normalizeCacheControl(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if err != nil && !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("normalizeCacheControl(%q) error = %v, want ErrInvalidInput", tt.value, err)
+		}
+		if got != tt.want {
+			t.Errorf("normalizeCacheControl(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestStorageOptionsBucketDefaults(t *testing.T) {
+	defaults, err := normalizeBucketStorageOptions(map[string]StorageOptions{
+		"backups": {StorageClass: "archive", CacheControl: "no-store"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	generator := &URLGenerator{bucketStorageOptions: defaults}
+
+	tests := []struct {
+		name     string
+		bucket   string
+		override StorageOptions
+		want     StorageOptions
+		wantErr  bool
+	}{
+		{"bucket defaults", "backups", StorageOptions{}, StorageOptions{StorageClass: "ARCHIVE", CacheControl: "no-store"}, false},
+		{"override wins", "backups", StorageOptions{StorageClass: "coldline"}, StorageOptions{StorageClass: "COLDLINE", CacheControl: "no-store"}, false},
+		{"fields are merged", "backups", StorageOptions{ContentLanguage: "en"}, StorageOptions{StorageClass: "ARCHIVE", CacheControl: "no-store", ContentLanguage: "en"}, false},
+		{"bucket without defaults", "documents", StorageOptions{ACL: "private"}, StorageOptions{ACL: "private"}, false},
+		{"invalid override", "backups", StorageOptions{StorageClass: "COLD"}, StorageOptions{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := generator.storageOptions(tt.bucket, tt.override)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("storageOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("storageOptions() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	_, err = normalizeBucketStorageOptions(map[string]StorageOptions{"backups": {StorageClass: "COLD"}})
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("invalid bucket defaults: error = %v, want ErrInvalidInput", err)
+	}
+}
@@ -0,0 +1,198 @@
+package gcsurl
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/tropikoearth/gcsurl/internal/hooks"
+)
+
+// UploadOptions customizes a signed upload URL
+type UploadOptions struct {
+	// Expiry overrides the default expiry when set
+	Expiry time.Duration
+	// UseOriginalName signs the exact object name instead of generating a unique one
+	UseOriginalName bool
+	// DoesNotExist rejects the upload if the object already exists (x-goog-if-generation-match:0)
+	DoesNotExist bool
+	// IfGenerationMatch only lets the upload replace this generation of the object
+	// Use it for optimistic concurrency; it requires UseOriginalName. 0 means no precondition.
+	IfGenerationMatch int64
+	// ContentHash is the hex digest of the file, used by the ContentHashNames strategy
+	// GCS cannot check it, so the URL is signed with x-goog-if-generation-match:0: a content-hash
+	// key is written once and a 412 means the content is already stored.
+	ContentHash string
+	// KeyValues fills the custom placeholders of Config.KeyTemplate (e.g. {"tenant": "acme"})
+	KeyValues map[string]string
+	// Metadata is stored as custom metadata and signed as required x-goog-meta-* headers
+	Metadata map[string]string
+	// EncryptionKey encrypts the object with a CSEK or CMEK key instead of Config.EncryptionKeys
+	EncryptionKey *EncryptionKey
+	// Storage sets the storage class, caching, encoding, language and ACL of the object
+	// Fields set here override Config.BucketStorageOptions.
+	Storage StorageOptions
+	// Checksum binds the upload to the MD5 and/or CRC32C of the file (Content-MD5 / x-goog-hash)
+	Checksum Checksum
+}
+
+// GenerateSignedUploadURLWithOptions generates a signed upload URL with options
+// Restrictions and unique naming are applied as in GenerateSignedUploadURL unless
+// UseOriginalName is set. Preconditions are signed as x-goog-if-generation-match, so GCS
+// rejects the upload with 412 Precondition Failed when they do not hold.
+func (u *URLGenerator) GenerateSignedUploadURLWithOptions(ctx context.Context, bucketName, objectName string, options UploadOptions) (DocumentUpload, error) {
+	headers, err := uploadHeaders(options)
+	if err != nil {
+		return DocumentUpload{}, err
+	}
+
+	// Generate unique object name with the configured strategy, inside the root prefix
+	key, err := u.uploadKey(objectName, options.ContentHash, options.KeyValues, options.UseOriginalName)
+	if err != nil {
+		return DocumentUpload{}, err
+	}
+	if err := u.authorize(ctx, OperationUpload, bucketName, key); err != nil {
+		return DocumentUpload{}, err
+	}
+
+	// Apply validation if restrictions are configured
+	if u.hasRestrictions() {
+		if err := u.ValidateUpload(objectName); err != nil {
+			return DocumentUpload{}, err
+		}
+		for name, value := range u.restrictedUploadHeaders(objectName) {
+			headers[name] = value
+		}
+	}
+	u.applySingleUpload(headers)
+
+	// Bucket defaults fill in the storage options the request leaves empty
+	for name, value := range u.bucketStorageOptions[bucketName].headers() {
+		if _, ok := headers[name]; !ok {
+			headers[name] = value
+		}
+	}
+
+	if options.EncryptionKey == nil {
+		encryptionKey, err := u.encryptionKey(ctx, nil, bucketName, key)
+		if err != nil {
+			return DocumentUpload{}, err
+		}
+		for name, value := range encryptionKey.uploadHeaders() {
+			headers[name] = value
+		}
+	}
+
+	expiry := u.defaultExpiry
+	if options.Expiry > 0 {
+		expiry = options.Expiry
+	}
+	expires := time.Now().Add(expiry)
+
+	opts, err := u.newSignedURLOptions(ctx, "PUT", expires)
+	if err != nil {
+		return DocumentUpload{}, err
+	}
+	applyHeaders(opts, headers)
+
+	signedURL, err := storage.SignedURL(bucketName, key, opts)
+	if err != nil {
+		return DocumentUpload{}, fmt.Errorf("failed to generate signed upload URL: %w", err)
+	}
+
+	upload := DocumentUpload{
+		UploadURL:    signedURL,
+		ExpiresAt:    expires,
+		GeneratedKey: key,
+		OriginalName: objectName,
+		Headers:      headers,
+	}
+	if !options.Checksum.isZero() {
+		checksum := options.Checksum
+		upload.Checksum = &checksum
+	}
+	return upload, nil
+}
+
+func init() {
+	hooks.UploadHeaders = func(options any) (map[string]string, error) {
+		return uploadHeaders(options.(UploadOptions))
+	}
+}
+
+// uploadHeaders returns the headers that follow from upload options alone
+// GenerateSignedUploadURLWithOptions starts from them and adds the generator's restrictions,
+// single upload precondition, bucket storage defaults and provided encryption keys.
+// gcsurltest.FakeGenerator reaches it through the internal hooks package.
+func uploadHeaders(options UploadOptions) (map[string]string, error) {
+	headers, err := uploadOptionHeaders(options)
+	if err != nil {
+		return nil, err
+	}
+	headers["Content-Type"] = "application/octet-stream"
+
+	storageOptions, err := options.Storage.normalize()
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range storageOptions.headers() {
+		headers[name] = value
+	}
+	for name, value := range options.Checksum.uploadHeaders() {
+		headers[name] = value
+	}
+	if options.EncryptionKey != nil {
+		if err := options.EncryptionKey.validate(); err != nil {
+			return nil, err
+		}
+		for name, value := range options.EncryptionKey.uploadHeaders() {
+			headers[name] = value
+		}
+	}
+	return headers, nil
+}
+
+// uploadOptionHeaders validates the per-request upload options and returns the precondition
+// and metadata headers they sign
+func uploadOptionHeaders(options UploadOptions) (map[string]string, error) {
+	if options.DoesNotExist && options.IfGenerationMatch != 0 {
+		return nil, inputErrorf("DoesNotExist and IfGenerationMatch cannot be combined")
+	}
+	if options.IfGenerationMatch < 0 {
+		return nil, inputErrorf("generation must be positive, got %d", options.IfGenerationMatch)
+	}
+	if options.IfGenerationMatch != 0 && !options.UseOriginalName {
+		// A freshly generated key has no earlier generation, so the upload could only fail
+		return nil, inputErrorf("IfGenerationMatch requires the requested object name as key (UseOriginalName or OriginalNames)")
+	}
+	if options.ContentHash != "" && options.IfGenerationMatch != 0 {
+		return nil, inputErrorf("content-hash keys are only written once and cannot use IfGenerationMatch")
+	}
+	if err := options.Checksum.validate(); err != nil {
+		return nil, err
+	}
+
+	headers, err := metadataHeaders(options.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case options.DoesNotExist, options.ContentHash != "":
+		// An unverified content hash must not overwrite the object stored under it
+		headers["x-goog-if-generation-match"] = "0"
+	case options.IfGenerationMatch > 0:
+		headers["x-goog-if-generation-match"] = strconv.FormatInt(options.IfGenerationMatch, 10)
+	}
+	return headers, nil
+}
+
+// applySingleUpload adds x-goog-if-generation-match:0 in single upload mode unless a precondition is set
+// The URL then stops working once the object exists.
+func (u *URLGenerator) applySingleUpload(headers map[string]string) map[string]string {
+	if _, ok := headers["x-goog-if-generation-match"]; !ok && !u.uploadRestrictions.AllowMultiple {
+		headers["x-goog-if-generation-match"] = "0"
+	}
+	return headers
+}
@@ -0,0 +1,38 @@
+package gcsurl
+
+import (
+	"context"
+	"time"
+)
+
+// UploadURLSigner signs upload URLs; *URLGenerator implements it
+// Depend on it instead of *URLGenerator to swap in a fake such as gcsurltest.FakeGenerator.
+type UploadURLSigner interface {
+	GenerateSignedUploadURL(ctx context.Context, objectName string) (DocumentUpload, error)
+	GenerateSignedUploadURLWithBucket(ctx context.Context, bucketName, objectName string) (DocumentUpload, error)
+	GenerateSignedUploadURLWithOptions(ctx context.Context, bucketName, objectName string, options UploadOptions) (DocumentUpload, error)
+	GenerateSignedUploadURLWithChecksum(ctx context.Context, objectName string, checksum Checksum) (DocumentUpload, error)
+	GenerateSignedUploadURLWithExpiry(ctx context.Context, bucketName, objectName string, expiry time.Duration) (DocumentUpload, error)
+	GenerateSignedUploadURLWithOriginalName(ctx context.Context, objectName string) (DocumentUpload, error)
+}
+
+// DownloadURLSigner signs download URLs; *URLGenerator implements it
+type DownloadURLSigner interface {
+	GenerateSignedDownloadURL(ctx context.Context, objectName string) (string, error)
+	GenerateSignedDownloadURLWithBucket(ctx context.Context, bucketName, objectName string) (string, error)
+	GenerateSignedDownloadURLWithExpiry(ctx context.Context, bucketName, objectName string, expiry time.Duration) (string, error)
+	GenerateSignedDownloadURLWithOptions(ctx context.Context, bucketName, objectName string, options DownloadOptions) (string, error)
+	GenerateSignedDownload(ctx context.Context, objectName string) (DocumentDownload, error)
+	GenerateSignedDownloadWithBucket(ctx context.Context, bucketName, objectName string) (DocumentDownload, error)
+	GenerateSignedDownloadWithOptions(ctx context.Context, bucketName, objectName string, options DownloadOptions) (DocumentDownload, error)
+}
+
+// URLSigner signs upload and download URLs and exposes the defaults they use; *URLGenerator implements it
+type URLSigner interface {
+	UploadURLSigner
+	DownloadURLSigner
+	GetBucketName() string
+	GetDefaultExpiry() time.Duration
+}
+
+var _ URLSigner = (*URLGenerator)(nil)
@@ -0,0 +1,351 @@
+package gcsurl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"cloud.google.com/go/storage"
+)
+
+// sniffLen is the number of leading bytes read to detect an object's type
+const sniffLen = 512
+
+// defaultQuarantinePrefix is where mismatched objects are moved when no prefix is configured
+const defaultQuarantinePrefix = "quarantine/"
+
+// ErrContentMismatch is returned by VerifyUpload when an object's content is not allowed
+var ErrContentMismatch = errors.New("uploaded content does not match the allowed types")
+
+// ObjectStore reads and moves uploaded objects for post-upload verification
+// The default implementation uses the storage client; implement it to verify against a local fake.
+type ObjectStore interface {
+	// ReadObjectPrefix returns up to n bytes from the start of the object
+	ReadObjectPrefix(ctx context.Context, bucketName, objectName string, n int64) ([]byte, error)
+	// CopyObject copies an object, used to move mismatches into quarantine
+	CopyObject(ctx context.Context, srcBucket, srcObject, dstBucket, dstObject string) error
+	// DeleteObject deletes an object
+	DeleteObject(ctx context.Context, bucketName, objectName string) error
+}
+
+// MismatchAction is what VerifyUpload does with an object whose content is not allowed
+type MismatchAction string
+
+const (
+	// MismatchReport leaves the object in place and only reports the mismatch
+	MismatchReport MismatchAction = "report"
+	// MismatchDelete deletes the object
+	MismatchDelete MismatchAction = "delete"
+	// MismatchQuarantine moves the object under the quarantine prefix
+	MismatchQuarantine MismatchAction = "quarantine"
+)
+
+// VerifyOptions customizes post-upload verification
+type VerifyOptions struct {
+	// OnMismatch is applied to objects that fail verification (default: MismatchReport)
+	OnMismatch MismatchAction
+	// QuarantineBucket receives quarantined objects; the object's bucket when empty
+	QuarantineBucket string
+	// QuarantinePrefix is prepended to quarantined keys (default: "quarantine/")
+	QuarantinePrefix string
+}
+
+// VerificationResult describes the detected type of an uploaded object
+type VerificationResult struct {
+	Bucket        string         `json:"bucket"`
+	Object        string         `json:"object"`
+	DetectedType  string         `json:"detectedType"`            // MIME type detected from magic bytes
+	ExpectedType  string         `json:"expectedType"`            // MIME type implied by the extension
+	Inconclusive  bool           `json:"inconclusive,omitempty"`  // Whether text-like content was empty or unrecognized, so the extension was trusted
+	Allowed       bool           `json:"allowed"`                 // Whether the content passed verification
+	Reason        string         `json:"reason,omitempty"`        // Why verification failed
+	Action        MismatchAction `json:"action,omitempty"`        // Action applied to a mismatch
+	QuarantineKey string         `json:"quarantineKey,omitempty"` // Where the object was moved to
+}
+
+// VerifyUpload checks the content of an uploaded object in the default bucket against the restrictions
+// Mismatches are only reported; use VerifyUploadWithOptions to delete or quarantine them.
+func (u *URLGenerator) VerifyUpload(ctx context.Context, objectName string) (VerificationResult, error) {
+	return u.VerifyUploadWithOptions(ctx, u.bucketName, objectName, VerifyOptions{})
+}
+
+// VerifyUploadWithOptions checks the content of an uploaded object in a specific bucket
+// The first bytes are matched against known magic numbers. The detected type must agree with
+// the object's extension and be allowed by AllowedExtensions or AllowedMIMETypes. Formats without
+// magic bytes (plain text, CSV, JSON, XML) are inconclusive when empty or unrecognized and judged
+// by the extension alone; for any other extension such content is a mismatch. A mismatch
+// returns the result together with an error wrapping ErrContentMismatch.
+func (u *URLGenerator) VerifyUploadWithOptions(ctx context.Context, bucketName, objectName string, options VerifyOptions) (VerificationResult, error) {
+	switch options.OnMismatch {
+	case "", MismatchReport, MismatchDelete, MismatchQuarantine:
+	default:
+		return VerificationResult{}, inputErrorf("unknown mismatch action %q", options.OnMismatch)
+	}
+
+	if err := u.checkObjectKey(objectName); err != nil {
+		return VerificationResult{}, err
+	}
+
+	store := u.getObjectStore()
+	head, err := store.ReadObjectPrefix(ctx, bucketName, objectName, sniffLen)
+	if err != nil {
+		return VerificationResult{}, fmt.Errorf("failed to read %s/%s: %w", bucketName, objectName, err)
+	}
+
+	result := VerificationResult{
+		Bucket:       bucketName,
+		Object:       objectName,
+		DetectedType: DetectContentType(head),
+		ExpectedType: getContentTypeFromExtension(strings.ToLower(path.Ext(objectName))),
+	}
+	unrecognized := len(head) == 0 || result.DetectedType == "application/octet-stream"
+	result.Inconclusive = unrecognized && !hasMagicBytes(result.ExpectedType)
+	result.Reason = u.contentMismatch(result.DetectedType, result.ExpectedType, result.Inconclusive)
+	if result.Reason != "" && len(head) == 0 && result.ExpectedType != "application/octet-stream" {
+		result.Reason = fmt.Sprintf("object is empty but the extension implies %s", result.ExpectedType)
+	}
+	result.Allowed = result.Reason == ""
+	if result.Allowed {
+		return result, nil
+	}
+
+	switch options.OnMismatch {
+	case MismatchDelete:
+		if err := store.DeleteObject(ctx, bucketName, objectName); err != nil {
+			return result, fmt.Errorf("failed to delete mismatched object %s/%s: %w", bucketName, objectName, err)
+		}
+		result.Action = MismatchDelete
+	case MismatchQuarantine:
+		quarantineBucket := options.QuarantineBucket
+		if quarantineBucket == "" {
+			quarantineBucket = bucketName
+		}
+		prefix := options.QuarantinePrefix
+		if prefix == "" {
+			prefix = defaultQuarantinePrefix
+		}
+		quarantineKey := prefix + objectName
+		if err := store.CopyObject(ctx, bucketName, objectName, quarantineBucket, quarantineKey); err != nil {
+			return result, fmt.Errorf("failed to quarantine %s/%s: %w", bucketName, objectName, err)
+		}
+		if err := store.DeleteObject(ctx, bucketName, objectName); err != nil {
+			return result, fmt.Errorf("failed to delete quarantined object %s/%s: %w", bucketName, objectName, err)
+		}
+		result.Action = MismatchQuarantine
+		result.QuarantineKey = quarantineKey
+	default:
+		result.Action = MismatchReport
+	}
+	return result, fmt.Errorf("%w: %s/%s: %s", ErrContentMismatch, bucketName, objectName, result.Reason)
+}
+
+// contentMismatch returns why the detected type is not acceptable, or "" when it is
+// Inconclusive content is checked as the type its extension implies.
+func (u *URLGenerator) contentMismatch(detected, expected string, inconclusive bool) string {
+	if inconclusive {
+		detected = expected
+	}
+	if expected != "application/octet-stream" && !contentTypeMatches(detected, expected) {
+		return fmt.Sprintf("content is %s but the extension implies %s", detected, expected)
+	}
+
+	allowed := u.uploadRestrictions.AllowedMIMETypes
+	for _, ext := range u.uploadRestrictions.AllowedExtensions {
+		allowed = append(allowed, getContentTypeFromExtension(strings.ToLower(ext)))
+	}
+	if len(allowed) == 0 {
+		return ""
+	}
+	for _, allowedType := range allowed {
+		if contentTypeMatches(detected, allowedType) {
+			return ""
+		}
+	}
+	return fmt.Sprintf("content type %s is not allowed", detected)
+}
+
+// hasMagicBytes reports whether content of a type starts with a recognizable signature
+// Text formats have none, so empty or unrecognized content cannot disprove them.
+func hasMagicBytes(contentType string) bool {
+	contentType = canonicalContentType(contentType)
+	return !strings.HasPrefix(contentType, "text/") && contentType != "application/json" && contentType != "application/xml"
+}
+
+// magicSignature maps a byte prefix at an offset to a MIME type
+type magicSignature struct {
+	offset   int
+	magic    []byte
+	mimeType string
+}
+
+// magicSignatures cover types http.DetectContentType does not recognize
+// Executables are listed so they are never mistaken for generic binary data.
+var magicSignatures = []magicSignature{
+	{0, []byte("MZ"), "application/vnd.microsoft.portable-executable"},
+	{0, []byte("\x7fELF"), "application/x-elf"},
+	{0, []byte("\xfe\xed\xfa\xce"), "application/x-mach-binary"},
+	{0, []byte("\xfe\xed\xfa\xcf"), "application/x-mach-binary"},
+	{0, []byte("\xce\xfa\xed\xfe"), "application/x-mach-binary"},
+	{0, []byte("\xcf\xfa\xed\xfe"), "application/x-mach-binary"},
+	{0, []byte("#!"), "text/x-shellscript"},
+	{0, []byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1"), "application/x-ole-storage"},
+	{0, []byte("7z\xbc\xaf\x27\x1c"), "application/x-7z-compressed"},
+	{0, []byte("BZh"), "application/x-bzip2"},
+	{0, []byte("\xfd7zXZ\x00"), "application/x-xz"},
+	{0, []byte("\xff\xfb"), "audio/mpeg"}, // MP3 frames without an ID3 tag
+	{0, []byte("\xff\xf3"), "audio/mpeg"},
+	{0, []byte("\xff\xf2"), "audio/mpeg"},
+	{0, []byte("II*\x00"), "image/tiff"},
+	{0, []byte("MM\x00*"), "image/tiff"},
+	{4, []byte("ftypheic"), "image/heic"},
+	{4, []byte("ftypqt"), "video/quicktime"},
+}
+
+// DetectContentType returns the MIME type of data from its magic bytes, without parameters
+// It extends http.DetectContentType with executables and a few container formats.
+func DetectContentType(data []byte) string {
+	for _, sig := range magicSignatures {
+		if len(data) >= sig.offset+len(sig.magic) && bytes.Equal(data[sig.offset:sig.offset+len(sig.magic)], sig.magic) {
+			return sig.mimeType
+		}
+	}
+	detected, _, err := mime.ParseMediaType(http.DetectContentType(data))
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return detected
+}
+
+// contentTypeAliases maps non-standard MIME types to the names getContentTypeFromExtension uses
+// http.DetectContentType reports AVI as video/avi and WAV as audio/wave, for example.
+var contentTypeAliases = map[string]string{
+	"video/avi":                    "video/x-msvideo",
+	"video/msvideo":                "video/x-msvideo",
+	"audio/mp3":                    "audio/mpeg",
+	"audio/mpeg3":                  "audio/mpeg",
+	"audio/x-mpeg":                 "audio/mpeg",
+	"audio/x-mp3":                  "audio/mpeg",
+	"audio/wave":                   "audio/wav",
+	"audio/x-wav":                  "audio/wav",
+	"image/jpg":                    "image/jpeg",
+	"image/pjpeg":                  "image/jpeg",
+	"image/x-png":                  "image/png",
+	"application/x-pdf":            "application/pdf",
+	"application/x-zip-compressed": "application/zip",
+}
+
+// canonicalContentType lowercases a MIME type and resolves aliases
+func canonicalContentType(contentType string) string {
+	contentType = strings.ToLower(contentType)
+	if canonical, ok := contentTypeAliases[contentType]; ok {
+		return canonical
+	}
+	return contentType
+}
+
+// contentTypeMatches reports whether a detected type satisfies an expected or allowed type
+// Sniffing cannot tell container formats and text formats apart, so those are matched by family.
+func contentTypeMatches(detected, allowed string) bool {
+	detected, allowed = canonicalContentType(detected), canonicalContentType(allowed)
+	if detected == allowed {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(allowed, "/*"); ok {
+		return strings.HasPrefix(detected, prefix+"/")
+	}
+
+	switch detected {
+	case "application/zip":
+		// Office Open XML and OpenDocument files are ZIP archives
+		return strings.Contains(allowed, "openxmlformats") || strings.Contains(allowed, "opendocument")
+	case "application/x-ole-storage":
+		// Legacy Office files are OLE compound documents
+		return allowed == "application/msword" || allowed == "application/vnd.ms-excel" || allowed == "application/vnd.ms-powerpoint"
+	case "text/plain", "text/xml":
+		return strings.HasPrefix(allowed, "text/") || allowed == "application/json" || allowed == "application/xml"
+	}
+	return false
+}
+
+// getObjectStore returns the configured object store or one backed by the storage client
+func (u *URLGenerator) getObjectStore() ObjectStore {
+	if u.objectStore != nil {
+		return u.objectStore
+	}
+	return &storageObjectStore{generator: u}
+}
+
+// storageObjectStore implements ObjectStore with the GCS storage client
+type storageObjectStore struct {
+	generator *URLGenerator
+}
+
+// ReadObjectPrefix reads up to n bytes from the start of the object
+func (s *storageObjectStore) ReadObjectPrefix(ctx context.Context, bucketName, objectName string, n int64) ([]byte, error) {
+	client, err := s.generator.CreateStorageClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	object, err := s.object(ctx, client, bucketName, objectName)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := object.NewRangeReader(ctx, 0, n)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// CopyObject copies an object with the storage client
+func (s *storageObjectStore) CopyObject(ctx context.Context, srcBucket, srcObject, dstBucket, dstObject string) error {
+	client, err := s.generator.CreateStorageClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	src, err := s.object(ctx, client, srcBucket, srcObject)
+	if err != nil {
+		return err
+	}
+	dst, err := s.object(ctx, client, dstBucket, dstObject)
+	if err != nil {
+		return err
+	}
+	_, err = dst.CopierFrom(src).Run(ctx)
+	return err
+}
+
+// object returns a handle for the object with its customer-supplied key, if any
+func (s *storageObjectStore) object(ctx context.Context, client *storage.Client, bucketName, objectName string) (*storage.ObjectHandle, error) {
+	object := client.Bucket(bucketName).Object(objectName)
+	encryptionKey, err := s.generator.encryptionKey(ctx, nil, bucketName, objectName)
+	if err != nil {
+		return nil, err
+	}
+	if encryptionKey.isCustomerSupplied() {
+		object = object.Key(encryptionKey.CustomerKey)
+	}
+	return object, nil
+}
+
+// DeleteObject deletes an object with the storage client
+func (s *storageObjectStore) DeleteObject(ctx context.Context, bucketName, objectName string) error {
+	client, err := s.generator.CreateStorageClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	return client.Bucket(bucketName).Object(objectName).Delete(ctx)
+}
@@ -0,0 +1,179 @@
+package gcsurl
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// memoryObjectStore is an in-memory ObjectStore keyed by "bucket/object"
+type memoryObjectStore map[string][]byte
+
+func (m memoryObjectStore) ReadObjectPrefix(ctx context.Context, bucketName, objectName string, n int64) ([]byte, error) {
+	data, ok := m[bucketName+"/"+objectName]
+	if !ok {
+		return nil, errors.New("object not found")
+	}
+	return data[:min(int64(len(data)), n)], nil
+}
+
+func (m memoryObjectStore) CopyObject(ctx context.Context, srcBucket, srcObject, dstBucket, dstObject string) error {
+	m[dstBucket+"/"+dstObject] = m[srcBucket+"/"+srcObject]
+	return nil
+}
+
+func (m memoryObjectStore) DeleteObject(ctx context.Context, bucketName, objectName string) error {
+	delete(m, bucketName+"/"+objectName)
+	return nil
+}
+
+func TestDetectContentTypeMatchesExtension(t *testing.T) {
+	tests := []struct {
+		name     string
+		ext      string
+		data     string
+		detected string
+	}{
+		{"pdf", ".pdf", "%PDF-1.7\n%\xe2\xe3\xcf\xd3\n", "application/pdf"},
+		{"png", ".png", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", "image/png"},
+		{"jpeg", ".jpg", "\xff\xd8\xff\xe0\x00\x10JFIF\x00", "image/jpeg"},
+		{"gif", ".gif", "GIF89a\x01\x00\x01\x00", "image/gif"},
+		{"webp", ".webp", "RIFF\x24\x00\x00\x00WEBPVP8 ", "image/webp"},
+		{"avi", ".avi", "RIFF\x24\x00\x00\x00AVI LIST", "video/avi"},
+		{"mp3 with ID3 tag", ".mp3", "ID3\x03\x00\x00\x00\x00\x00\x00", "audio/mpeg"},
+		{"mp3 without ID3 tag", ".mp3", "\xff\xfb\x90\x64\x00\x00\x00\x00", "audio/mpeg"},
+		{"mp4", ".mp4", "\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isom", "video/mp4"},
+		{"zip", ".zip", "PK\x03\x04\x14\x00\x00\x00", "application/zip"},
+		{"docx", ".docx", "PK\x03\x04\x14\x00\x06\x00", "application/zip"},
+		{"doc", ".doc", "\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1\x00\x00", "application/x-ole-storage"},
+		{"csv", ".csv", "name,size\nreport.pdf,1024\n", "text/plain"},
+		{"json", ".json", `{"name": "report"}`, "text/plain"},
+		{"xml", ".xml", `<?xml version="1.0"?><root/>`, "text/xml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detected := DetectContentType([]byte(tt.data))
+			if detected != tt.detected {
+				t.Errorf("DetectContentType() = %q, want %q", detected, tt.detected)
+			}
+			expected := getContentTypeFromExtension(tt.ext)
+			if !contentTypeMatches(detected, expected) {
+				t.Errorf("contentTypeMatches(%q, %q) = false, want true", detected, expected)
+			}
+		})
+	}
+}
+
+func TestContentTypeMatches(t *testing.T) {
+	tests := []struct {
+		detected string
+		allowed  string
+		want     bool
+	}{
+		{"image/png", "image/png", true},
+		{"image/png", "IMAGE/PNG", true},
+		{"image/png", "image/*", true},
+		{"video/avi", "video/x-msvideo", true},
+		{"audio/mpeg", "audio/mp3", true},
+		{"audio/wave", "audio/x-wav", true},
+		{"image/jpeg", "image/jpg", true},
+		{"text/plain", "text/csv", true},
+		{"text/plain", "application/json", true},
+		{"application/zip", "application/vnd.openxmlformats-officedocument.wordprocessingml.document", true},
+		{"application/x-ole-storage", "application/vnd.ms-excel", true},
+		{"image/png", "image/jpeg", false},
+		{"image/png", "video/*", false},
+		{"application/vnd.microsoft.portable-executable", "application/pdf", false},
+		{"application/zip", "application/pdf", false},
+	}
+
+	for _, tt := range tests {
+		if got := contentTypeMatches(tt.detected, tt.allowed); got != tt.want {
+			t.Errorf("contentTypeMatches(%q, %q) = %v, want %v", tt.detected, tt.allowed, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyUpload(t *testing.T) {
+	store := memoryObjectStore{
+		"b/report.pdf":   []byte("%PDF-1.7\n"),
+		"b/clip.avi":     []byte("RIFF\x24\x00\x00\x00AVI LIST"),
+		"b/song.mp3":     []byte("\x00\x00\x01\x02unrecognized frames"),
+		"b/empty.pdf":    nil,
+		"b/empty.txt":    nil,
+		"b/data.json":    []byte("\x00\x01binary\x02"),
+		"b/invoice.pdf":  []byte("MZ\x90\x00\x03\x00\x00\x00"),
+		"b/photo.png":    []byte("\x89PNG\r\n\x1a\n"),
+		"b/data.bin":     []byte("\x00\x01\x02\x03"),
+		"b/script.txt":   []byte("#!/bin/sh\nrm -rf /\n"),
+		"b/contract.doc": []byte("%PDF-1.4\n"),
+	}
+	generator := &URLGenerator{
+		bucketName:  "b",
+		objectStore: store,
+		uploadRestrictions: UploadRestrictions{
+			AllowedExtensions: []string{".pdf", ".avi", ".mp3", ".txt", ".json", ".doc"},
+		},
+	}
+
+	tests := []struct {
+		object       string
+		allowed      bool
+		inconclusive bool
+	}{
+		{"report.pdf", true, false},
+		{"clip.avi", true, false},
+		{"song.mp3", false, false},
+		{"empty.pdf", false, false},
+		{"empty.txt", true, true},
+		{"data.json", true, true},
+		{"invoice.pdf", false, false},
+		{"photo.png", false, false},
+		{"data.bin", false, false},
+		{"script.txt", false, false},
+		{"contract.doc", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.object, func(t *testing.T) {
+			result, err := generator.VerifyUpload(context.Background(), tt.object)
+			if result.Allowed != tt.allowed || result.Inconclusive != tt.inconclusive {
+				t.Errorf("VerifyUpload() = %+v, want allowed %v and inconclusive %v", result, tt.allowed, tt.inconclusive)
+			}
+			if tt.allowed && err != nil {
+				t.Errorf("VerifyUpload() error = %v", err)
+			}
+			if !tt.allowed && !errors.Is(err, ErrContentMismatch) {
+				t.Errorf("VerifyUpload() error = %v, want ErrContentMismatch", err)
+			}
+		})
+	}
+}
+
+func TestVerifyUploadQuarantine(t *testing.T) {
+	store := memoryObjectStore{"b/users/1/invoice.pdf": []byte("MZ\x90\x00")}
+	generator := &URLGenerator{bucketName: "b", objectStore: store}
+
+	result, err := generator.VerifyUploadWithOptions(context.Background(), "b", "users/1/invoice.pdf", VerifyOptions{OnMismatch: MismatchQuarantine})
+	if !errors.Is(err, ErrContentMismatch) {
+		t.Fatalf("VerifyUploadWithOptions() error = %v, want ErrContentMismatch", err)
+	}
+	if result.Action != MismatchQuarantine || result.QuarantineKey != "quarantine/users/1/invoice.pdf" {
+		t.Errorf("VerifyUploadWithOptions() = %+v", result)
+	}
+	if _, ok := store["b/users/1/invoice.pdf"]; ok {
+		t.Error("mismatched object was not removed")
+	}
+	if _, ok := store["b/quarantine/users/1/invoice.pdf"]; !ok {
+		t.Error("mismatched object was not quarantined")
+	}
+}
+
+func TestVerifyUploadUnknownAction(t *testing.T) {
+	generator := &URLGenerator{bucketName: "b", objectStore: memoryObjectStore{}}
+	_, err := generator.VerifyUploadWithOptions(context.Background(), "b", "report.pdf", VerifyOptions{OnMismatch: "archive"})
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("VerifyUploadWithOptions() error = %v, want ErrInvalidInput", err)
+	}
+}